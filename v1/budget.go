@@ -0,0 +1,265 @@
+package smspartner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrBudgetExceeded = errors.New("Budget exceeded")
+
+// ErrBudgetLedger is returned when a BudgetGuard has no ledger to record the
+// costs in.
+var ErrBudgetLedger = errors.New("Budget guard requires a ledger")
+
+// Limits of a BudgetGuard reported by a BudgetExceededError.
+const (
+	LimitBalance = "balance"
+	LimitDaily   = "daily"
+	LimitMonthly = "monthly"
+)
+
+// BudgetExceededError is returned when sending would exceed a limit of the
+// budget. It matches ErrBudgetExceeded with errors.Is.
+type BudgetExceededError struct {
+	Limit     string // LimitBalance, LimitDaily or LimitMonthly
+	Estimate  Money
+	Remaining Money
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("estimated cost %s exceeds the remaining %s budget of %s", e.Estimate, e.Limit, e.Remaining)
+}
+
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// Pricer returns the price of one SMS segment sent to phoneNumber in gamme.
+type Pricer interface {
+	Price(phoneNumber string, gamme Gamme) (Money, error)
+}
+
+// FlatPricer prices every segment of a gamme the same, whatever the country.
+type FlatPricer struct {
+	Premium Money
+	LowCost Money
+}
+
+// Price returns the price of gamme, Premium being the default of the API.
+func (p FlatPricer) Price(phoneNumber string, gamme Gamme) (Money, error) {
+	switch gamme {
+	case 0, Premium:
+		return p.Premium, nil
+	case LowCost:
+		return p.LowCost, nil
+	}
+	return Money{}, fmt.Errorf("no price for gamme %d", gamme)
+}
+
+// EstimateSMS returns the cost of sms: the price of each recipient times
+// the number of segments of the message.
+func EstimateSMS(p Pricer, sms *SMS) (Money, error) {
+	est, err := EstimateSMSCost(p, sms)
+	if err != nil {
+		return Money{}, err
+	}
+	return est.Total, nil
+}
+
+// EstimateBulkSMS returns the cost of bulksms, see EstimateSMS.
+func EstimateBulkSMS(p Pricer, bulksms *BulkSMS) (Money, error) {
+	est, err := EstimateBulkSMSCost(p, bulksms)
+	if err != nil {
+		return Money{}, err
+	}
+	return est.Total, nil
+}
+
+// BudgetGuard refuses to send SMS whose estimated cost exceeds the balance
+// of the account or the daily or monthly caps. The actual costs are
+// recorded in Ledger, which is read to enforce the caps. See the Budget option.
+type BudgetGuard struct {
+	Pricer       Pricer
+	Ledger       *Ledger
+	DailyCap     Money // no limit if zero
+	MonthlyCap   Money // no limit if zero
+	CheckBalance bool  // compare with the balance returned by CheckCredits
+
+	// OnError is called when a cost could not be recorded in the ledger.
+	// The cost is still counted until the process exits.
+	OnError func(error)
+
+	mu         sync.Mutex
+	pending    Money // estimates of the sends in progress
+	unrecorded Money
+}
+
+// NewBudgetGuard returns a guard estimating costs with pricer and recording
+// them in ledger, which is required.
+func NewBudgetGuard(pricer Pricer, ledger *Ledger) *BudgetGuard {
+	return &BudgetGuard{Pricer: pricer, Ledger: ledger, CheckBalance: true}
+}
+
+// reserve checks that estimate fits in the budget and counts it as pending
+// until release is called.
+func (g *BudgetGuard) reserve(ctx context.Context, c *Client, estimate Money) error {
+	// Checked before sending, as the cost could not be recorded after.
+	if g.Ledger == nil {
+		return ErrBudgetLedger
+	}
+	var balance *Money
+	if g.CheckBalance {
+		resp, err := c.checkCredits(ctx)
+		if err != nil {
+			return err
+		}
+		if resp.Credits == nil {
+			return fmt.Errorf("unexpected response: no credits")
+		}
+		balance = &resp.Credits.Balance
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	committed, err := g.pending.Add(g.unrecorded)
+	if err != nil {
+		return err
+	}
+	if balance != nil {
+		if err := checkLimit(LimitBalance, *balance, g.pending, estimate); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().In(apiLocation)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, apiLocation)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, apiLocation)
+	for _, p := range []struct {
+		limit    string
+		max      Money
+		from, to time.Time
+	}{
+		{LimitDaily, g.DailyCap, day, day.AddDate(0, 0, 1)},
+		{LimitMonthly, g.MonthlyCap, month, month.AddDate(0, 1, 0)},
+	} {
+		if p.max.IsZero() {
+			continue
+		}
+		spent, err := g.Ledger.Spent(p.from, p.to)
+		if err != nil {
+			return err
+		}
+		if spent, err = spent.Add(committed); err != nil {
+			return err
+		}
+		if err := checkLimit(p.limit, p.max, spent, estimate); err != nil {
+			return err
+		}
+	}
+
+	g.pending, err = g.pending.Add(estimate)
+	return err
+}
+
+// checkLimit returns a *BudgetExceededError if spent+estimate exceeds max.
+func checkLimit(limit string, max, spent, estimate Money) error {
+	remaining, err := max.Sub(spent)
+	if err != nil {
+		return err
+	}
+	n, err := estimate.Cmp(remaining)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return &BudgetExceededError{Limit: limit, Estimate: estimate, Remaining: remaining}
+	}
+	return nil
+}
+
+// release records e, if the SMS have been sent, and then ends the
+// reservation of estimate.
+func (g *BudgetGuard) release(estimate Money, e *LedgerEntry) {
+	var err error
+	if e != nil {
+		err = g.Ledger.Append(e)
+	}
+
+	g.mu.Lock()
+	g.pending, _ = g.pending.Sub(estimate)
+	if err != nil {
+		g.unrecorded, _ = g.unrecorded.Add(e.Cost)
+	}
+	g.mu.Unlock()
+
+	if err != nil && g.OnError != nil {
+		g.OnError(fmt.Errorf("cost of message %d could not be recorded: %v", e.MessageID, err))
+	}
+}
+
+// guard reserves the cost of a send with the budget of c, if any, and returns
+// the function to call with its ledger entry once sent, or nil if it failed.
+// See bill.
+func (c *Client) guard(ctx context.Context, estimate func(Pricer) (Money, error)) (func(*LedgerEntry), error) {
+	if c.budget == nil {
+		return func(e *LedgerEntry) { c.bill(Money{}, e) }, nil
+	}
+	est, err := estimate(c.budget.Pricer)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.budget.reserve(ctx, c, est); err != nil {
+		return nil, err
+	}
+	return func(e *LedgerEntry) { c.bill(est, e) }, nil
+}
+
+// guardBatches reserves at once the cost of all batches with the budget of c,
+// if any, so that a send exceeding it is refused before any batch is sent.
+// It returns the function to call with the ledger entry of batch i once sent,
+// or nil if it failed, and the function ending the reservation of the
+// batches for which it was not called.
+func (c *Client) guardBatches(ctx context.Context, batches []*BulkSMS) (func(i int, e *LedgerEntry), func(), error) {
+	if c.budget == nil {
+		return func(_ int, e *LedgerEntry) { c.bill(Money{}, e) }, func() {}, nil
+	}
+	estimates := make([]Money, len(batches))
+	var total Money
+	for i, batch := range batches {
+		est, err := EstimateBulkSMS(c.budget.Pricer, batch)
+		if err != nil {
+			return nil, nil, err
+		}
+		if total, err = total.Add(est); err != nil {
+			return nil, nil, err
+		}
+		estimates[i] = est
+	}
+	if err := c.budget.reserve(ctx, c, total); err != nil {
+		return nil, nil, err
+	}
+
+	var mu sync.Mutex
+	billed := make([]bool, len(batches))
+	done := func(i int, e *LedgerEntry) {
+		mu.Lock()
+		billed[i] = true
+		mu.Unlock()
+		c.bill(estimates[i], e)
+	}
+	finish := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for i := range billed {
+			if !billed[i] {
+				billed[i] = true
+				c.budget.release(estimates[i], nil)
+			}
+		}
+	}
+	return done, finish, nil
+}
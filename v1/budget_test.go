@@ -0,0 +1,210 @@
+package smspartner_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+// budgetHandler serves a main account balance and the send fixtures.
+type budgetHandler struct {
+	balance string
+	sent    int
+	t       *testing.T
+}
+
+func (h *budgetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var name string
+	switch r.URL.Path {
+	case "/v1/me":
+		fmt.Fprintf(w, `{"success": true, "code": 200, "credits": {"balance": %q, "currency": "EUR"}}`, h.balance)
+		return
+	case "/v1/send", "/v1/vn/send":
+		h.sent++
+		name = "send_sms.json"
+	case "/v1/bulk-send":
+		h.sent++
+		name = "send_bulksms.json"
+	default:
+		h.t.Errorf("unexpected request: %s", r.URL.Path)
+		return
+	}
+	b, err := fixture(name)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	fmt.Fprint(w, string(b))
+}
+
+func TestEstimateSMS(t *testing.T) {
+	pricer := smspartner.FlatPricer{
+		Premium: smspartner.MustParseMoney("0.05", "EUR"),
+		LowCost: smspartner.MustParseMoney("0.03", "EUR"),
+	}
+
+	// 2 recipients, 2 segments
+	sms := &smspartner.SMS{PhoneNumbers: "0620123456, 0621123456", Message: strings.Repeat("a", 161)}
+	got, err := smspartner.EstimateSMS(pricer, sms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "0.2 EUR" {
+		t.Errorf("got: %s, want: %s", got, "0.2 EUR")
+	}
+
+	bulksms := &smspartner.BulkSMS{Gamme: smspartner.LowCost, SMSList: []*smspartner.SMSPayload{
+		{PhoneNumber: "0620123456", Message: "Hello"},
+		{PhoneNumber: "0621123456", Message: "Été"},
+	}}
+	if got, err = smspartner.EstimateBulkSMS(pricer, bulksms); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "0.06 EUR" {
+		t.Errorf("got: %s, want: %s", got, "0.06 EUR")
+	}
+}
+
+func TestBudgetGuard(t *testing.T) {
+	h := &budgetHandler{balance: "100", t: t}
+	ledger := smspartner.NewLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	guard := smspartner.NewBudgetGuard(smspartner.FlatPricer{Premium: smspartner.MustParseMoney("0.05", "EUR")}, ledger)
+	guard.DailyCap = smspartner.MustParseMoney("1", "EUR")
+
+	cli, teardown := testingHTTPClient(t, h, smspartner.Budget(guard))
+	defer teardown()
+
+	if _, err := cli.SendSMS(&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ledger.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].MessageID != 2270142 || entries[0].Cost.String() != "0.04 EUR" {
+		t.Errorf("unexpected ledger: %s", jsonString(entries))
+	}
+
+	if err := ledger.Append(&smspartner.LedgerEntry{
+		Time:     time.Now(),
+		Endpoint: "bulk-send",
+		Cost:     smspartner.MustParseMoney("0.9", "EUR"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 0.04 + 0.9 spent today, 0.10 estimated
+	_, err = cli.SendBulkSMS(&smspartner.BulkSMS{SMSList: []*smspartner.SMSPayload{
+		{PhoneNumber: "0620123456", Message: "Hello"},
+		{PhoneNumber: "0621123456", Message: "Hello"},
+	}})
+
+	var budgetErr *smspartner.BudgetExceededError
+	if !errors.As(err, &budgetErr) || !errors.Is(err, smspartner.ErrBudgetExceeded) {
+		t.Fatalf("got: %v, want a *BudgetExceededError", err)
+	}
+	if budgetErr.Limit != smspartner.LimitDaily || budgetErr.Estimate.String() != "0.1 EUR" || budgetErr.Remaining.String() != "0.06 EUR" {
+		t.Errorf("unexpected error: %#v", budgetErr)
+	}
+	if h.sent != 1 {
+		t.Errorf("sent %d requests, want: 1", h.sent)
+	}
+}
+
+func TestBudgetGuardBalance(t *testing.T) {
+	h := &budgetHandler{balance: "0.040", t: t}
+	ledger := smspartner.NewLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	guard := smspartner.NewBudgetGuard(smspartner.FlatPricer{Premium: smspartner.MustParseMoney("0.05", "EUR")}, ledger)
+
+	cli, teardown := testingHTTPClient(t, h, smspartner.Budget(guard))
+	defer teardown()
+
+	_, err := cli.SendSMS(&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Hello"})
+
+	var budgetErr *smspartner.BudgetExceededError
+	if !errors.As(err, &budgetErr) || budgetErr.Limit != smspartner.LimitBalance {
+		t.Fatalf("got: %v, want a *BudgetExceededError", err)
+	}
+
+	_, err = cli.SendVirtualNumber(&smspartner.VNumber{To: "+33620123456", From: "+33757000000", Message: "Hello"})
+	if !errors.As(err, &budgetErr) || budgetErr.Limit != smspartner.LimitBalance {
+		t.Fatalf("got: %v, want a *BudgetExceededError", err)
+	}
+	if h.sent != 0 {
+		t.Errorf("sent %d requests, want: 0", h.sent)
+	}
+}
+
+func TestBudgetGuardWithoutLedger(t *testing.T) {
+	guard := smspartner.NewBudgetGuard(smspartner.FlatPricer{Premium: smspartner.MustParseMoney("0.05", "EUR")}, nil)
+	if _, err := smspartner.NewClient(&http.Client{}, smspartner.APIKey("TEST_API_KEY"), smspartner.Budget(guard)); err != smspartner.ErrBudgetLedger {
+		t.Errorf("got: %v, want: %v", err, smspartner.ErrBudgetLedger)
+	}
+
+	// The ledger is removed after the client is created.
+	h := &budgetHandler{balance: "100", t: t}
+	guard.Ledger = smspartner.NewLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	guard.DailyCap = smspartner.MustParseMoney("1", "EUR")
+	cli, teardown := testingHTTPClient(t, h, smspartner.Budget(guard))
+	defer teardown()
+	guard.Ledger = nil
+
+	if _, err := cli.SendSMS(&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Hello"}); err != smspartner.ErrBudgetLedger {
+		t.Errorf("got: %v, want: %v", err, smspartner.ErrBudgetLedger)
+	}
+	if h.sent != 0 {
+		t.Errorf("sent %d requests, want: 0", h.sent)
+	}
+}
+
+func TestBudgetGuardBatches(t *testing.T) {
+	h := &budgetHandler{balance: "100", t: t}
+	ledger := smspartner.NewLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	guard := smspartner.NewBudgetGuard(smspartner.FlatPricer{Premium: smspartner.MustParseMoney("0.05", "EUR")}, ledger)
+	guard.DailyCap = smspartner.MustParseMoney("30", "EUR")
+
+	cli, teardown := testingHTTPClient(t, h, smspartner.Budget(guard))
+	defer teardown()
+
+	// 700 recipients in 2 batches: 35 EUR estimated, over the cap.
+	newList := func() []*smspartner.SMSPayload {
+		var list []*smspartner.SMSPayload
+		for i := 0; i < 700; i++ {
+			list = append(list, &smspartner.SMSPayload{PhoneNumber: fmt.Sprintf("0620%06d", i), Message: "Hello"})
+		}
+		return list
+	}
+
+	_, err := cli.SendBulkSMSBatches(context.Background(), &smspartner.BulkSMS{SMSList: newList()}, 2)
+	if !errors.Is(err, smspartner.ErrBudgetExceeded) {
+		t.Errorf("got: %v, want: %v", err, smspartner.ErrBudgetExceeded)
+	}
+	cmp := cli.NewCampaign("over-budget", t.TempDir(), &smspartner.BulkSMS{SMSList: newList()})
+	if _, err := cmp.Run(context.Background()); !errors.Is(err, smspartner.ErrBudgetExceeded) {
+		t.Errorf("got: %v, want: %v", err, smspartner.ErrBudgetExceeded)
+	}
+	if h.sent != 0 {
+		t.Errorf("sent %d requests, want: 0", h.sent)
+	}
+
+	// Within the cap, the reservation is released as the batches are sent.
+	guard.DailyCap = smspartner.MustParseMoney("100", "EUR")
+	if _, err := cli.SendBulkSMSBatches(context.Background(), &smspartner.BulkSMS{SMSList: newList()}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if h.sent != 2 {
+		t.Errorf("sent %d requests, want: 2", h.sent)
+	}
+	guard.DailyCap = smspartner.MustParseMoney("1", "EUR")
+	if _, err := cli.SendSMS(&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Hello"}); err != nil {
+		t.Errorf("the reservation of the batches was not released: %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package smspartner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// MaxBulkSMS is the maximum number of SMS accepted by a single bulk-send request.
+const MaxBulkSMS = 500
+
+// defaultBulkConcurrency is the number of batches sent in parallel when
+// SendBulkSMSBatches is called with a concurrency lower than 1.
+const defaultBulkConcurrency = 4
+
+var ErrBulkSMSLimit = fmt.Errorf("SMSList can not contain more than %d SMS", MaxBulkSMS)
+
+// SplitBulkSMS splits bulksms into batches of at most size SMS (MaxBulkSMS if
+// size is out of range). Every batch keeps the sending parameters of bulksms.
+func SplitBulkSMS(bulksms *BulkSMS, size int) []*BulkSMS {
+	if size < 1 || size > MaxBulkSMS {
+		size = MaxBulkSMS
+	}
+
+	var batches []*BulkSMS
+	for start := 0; start < len(bulksms.SMSList); start += size {
+		end := start + size
+		if end > len(bulksms.SMSList) {
+			end = len(bulksms.SMSList)
+		}
+		batch := *bulksms
+		batch.SMSList = bulksms.SMSList[start:end:end]
+		batches = append(batches, &batch)
+	}
+	return batches
+}
+
+// BatchResult is the outcome of one bulk-send request.
+type BatchResult struct {
+	Index    int
+	SMSList  []*SMSPayload
+	Response *BulkSMSResponse
+	Err      error
+}
+
+// RecipientResult is the outcome of a single SMS of a batched bulk send.
+// Response is nil when the whole batch failed.
+type RecipientResult struct {
+	Batch       int
+	PhoneNumber string
+	MessageID   int
+	Response    *SMSResponse
+	Err         error
+}
+
+// BulkSendResult merges the responses of all the batches of a bulk send.
+type BulkSendResult struct {
+	MessageIDs  []int
+	Cost        Money
+	Currency    string
+	NumberOfSMS int
+	Batches     []*BatchResult
+	Recipients  []*RecipientResult
+	Excluded    []*ExcludedRecipient // see Suppress and FilterNumbers
+	Route       *Route               // see the Routing option
+}
+
+// Failed returns the batches that could not be sent.
+func (r *BulkSendResult) Failed() []*BatchResult {
+	var failed []*BatchResult
+	for _, b := range r.Batches {
+		if b.Err != nil {
+			failed = append(failed, b)
+		}
+	}
+	return failed
+}
+
+// BulkSendError is returned by SendBulkSMSBatches when at least one batch failed.
+type BulkSendError struct {
+	Total  int
+	Failed []*BatchResult
+}
+
+func (e *BulkSendError) Error() string {
+	return fmt.Sprintf("%d of %d batches failed: %v", len(e.Failed), e.Total, e.Failed[0].Err)
+}
+
+func (e *BulkSendError) Unwrap() error {
+	return e.Failed[0].Err
+}
+
+// SendBulkSMSBatches sends any number of SMS by splitting bulksms.SMSList into
+// batches of MaxBulkSMS, sending at most concurrency batches at the same time.
+// The returned result is never nil: when some batches fail, it holds the
+// outcome of every batch and the error is a *BulkSendError. When the batches
+// are billed in different currencies, Cost only sums the first currency and
+// the error wraps ErrCurrencyMismatch.
+func (c *Client) SendBulkSMSBatches(ctx context.Context, bulksms *BulkSMS, concurrency int) (*BulkSendResult, error) {
+	if len(bulksms.SMSList) == 0 {
+		return nil, errors.New("SMSList is empty")
+	}
+	if !c.skipValidation {
+		if err := bulksms.validate(0); err != nil {
+			return nil, err
+		}
+	}
+	excluded, err := c.suppressBulkSMS(ctx, bulksms)
+	if err != nil {
+		return nil, err
+	}
+	if concurrency < 1 {
+		concurrency = defaultBulkConcurrency
+	}
+
+	// The whole list is routed, and its cost reserved, before sending any batch.
+	route, err := c.routeBulkSMS(ctx, bulksms)
+	if err != nil {
+		return nil, err
+	}
+	batches := SplitBulkSMS(routedBulkSMS(bulksms, route), MaxBulkSMS)
+	done, finish, err := c.guardBatches(ctx, batches)
+	if err != nil {
+		return nil, err
+	}
+	defer finish()
+	results := make([]*BatchResult, len(batches))
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		recordErr error
+	)
+	sem := make(chan struct{}, concurrency)
+	for i, batch := range batches {
+		results[i] = &BatchResult{Index: i, SMSList: batch.SMSList}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(br *BatchResult, batch *BulkSMS) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := ctx.Err(); err != nil {
+				br.Err = err
+				return
+			}
+			br.Response, br.Err = c.postBulkSMS(ctx, batch, func(e *LedgerEntry) { done(br.Index, e) })
+			if br.Err != nil {
+				return
+			}
+			if err := c.recordBulkSMS(batch, br.Response, ""); err != nil {
+				mu.Lock()
+				if recordErr == nil {
+					recordErr = err
+				}
+				mu.Unlock()
+			}
+		}(results[i], batch)
+	}
+	wg.Wait()
+
+	res, mergeErr := mergeBatchResults(results)
+	res.Excluded, res.Route = excluded, route
+	if failed := res.Failed(); len(failed) > 0 {
+		return res, &BulkSendError{Total: len(results), Failed: failed}
+	}
+	if mergeErr != nil {
+		return res, mergeErr
+	}
+	if recordErr != nil {
+		return res, recordErr
+	}
+	return res, nil
+}
+
+// pairedResponses returns the responses to the n SMS of a bulk-send request,
+// in order, or nil if they can not be paired with the SMS.
+func pairedResponses(resp *BulkSMSResponse, n int) []*SMSResponse {
+	// The API answers in the order of SMSList; when the lengths do not
+	// match we can not pair responses with recipients.
+	if len(resp.SMSResponseList) != n {
+		return nil
+	}
+	return resp.SMSResponseList
+}
+
+func mergeBatchResults(batches []*BatchResult) (*BulkSendResult, error) {
+	res := &BulkSendResult{Batches: batches}
+	var mergeErr error
+	for _, b := range batches {
+		if b.Err != nil {
+			for _, sms := range b.SMSList {
+				if sms == nil {
+					continue
+				}
+				res.Recipients = append(res.Recipients, &RecipientResult{
+					Batch:       b.Index,
+					PhoneNumber: sms.PhoneNumber,
+					Err:         b.Err,
+				})
+			}
+			continue
+		}
+
+		r := b.Response
+		res.MessageIDs = append(res.MessageIDs, r.MessageID)
+		// The batches are billed to the same account, normally in one
+		// currency: a mismatch is reported rather than summed.
+		if cost, err := res.Cost.Add(r.Cost); err != nil {
+			if mergeErr == nil {
+				mergeErr = fmt.Errorf("cost of batch %d: %w", b.Index, err)
+			}
+		} else {
+			res.Cost = cost
+		}
+		res.NumberOfSMS += r.NumberOfSMS
+		if res.Currency == "" {
+			res.Currency = r.Currency
+		}
+
+		responses := pairedResponses(r, len(b.SMSList))
+		for i, sms := range b.SMSList {
+			if sms == nil {
+				continue
+			}
+			rr := &RecipientResult{
+				Batch:       b.Index,
+				PhoneNumber: sms.PhoneNumber,
+				MessageID:   r.MessageID,
+			}
+			if responses != nil && responses[i] != nil {
+				rr.Response = responses[i]
+				if !rr.Response.Success {
+					rr.Err = fmt.Errorf("SMS to %s failed with code %d", sms.PhoneNumber, rr.Response.Code)
+				}
+			}
+			res.Recipients = append(res.Recipients, rr)
+		}
+	}
+	return res, mergeErr
+}
@@ -0,0 +1,148 @@
+package smspartner_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+func TestSplitBulkSMS(t *testing.T) {
+	bulksms := &smspartner.BulkSMS{Sender: "Shop", SMSList: testSMSList(1201)}
+
+	batches := smspartner.SplitBulkSMS(bulksms, 0)
+	if len(batches) != 3 {
+		t.Fatalf("got: %d batches, want: %d", len(batches), 3)
+	}
+	for i, want := range []int{500, 500, 201} {
+		if got := len(batches[i].SMSList); got != want {
+			t.Errorf("#%d. got: %d SMS, want: %d", i, got, want)
+		}
+		if batches[i].Sender != "Shop" {
+			t.Errorf("#%d. sending parameters not copied: %#v", i, batches[i])
+		}
+	}
+}
+
+func TestSendBulkSMSBatches(t *testing.T) {
+	var mu sync.Mutex
+	var nextID int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req smspartner.BulkSMS
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		// fail the batch holding the 600th recipient
+		if req.SMSList[0].PhoneNumber == "+33600000500" {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"success": false, "code": 10, "message": "Erreur interne"}`)
+			return
+		}
+
+		mu.Lock()
+		nextID++
+		id := nextID
+		mu.Unlock()
+
+		cost := smspartner.MustParseMoney("0.04", "EUR")
+		resp := smspartner.BulkSMSResponse{Success: true, Code: 200, MessageID: id, Currency: "EUR"}
+		for _, sms := range req.SMSList {
+			resp.Cost, _ = resp.Cost.Add(cost)
+			resp.NumberOfSMS++
+			resp.SMSResponseList = append(resp.SMSResponseList, &smspartner.SMSResponse{
+				Success: true, Code: 200, NumberOfSMS: 1, Cost: cost, PhoneNumber: sms.PhoneNumber,
+			})
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	bulksms := &smspartner.BulkSMS{SMSList: testSMSList(1201)}
+	res, err := cli.SendBulkSMSBatches(context.Background(), bulksms, 2)
+
+	var bulkErr *smspartner.BulkSendError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("got: %v, want a *BulkSendError", err)
+	}
+	if len(bulkErr.Failed) != 1 || bulkErr.Failed[0].Index != 1 {
+		t.Errorf("got: %#v, want batch #1 to fail", bulkErr.Failed)
+	}
+
+	if len(res.MessageIDs) != 2 {
+		t.Errorf("got: %d message IDs, want: %d", len(res.MessageIDs), 2)
+	}
+	if res.NumberOfSMS != 701 {
+		t.Errorf("got: %d SMS, want: %d", res.NumberOfSMS, 701)
+	}
+	if got := res.Cost.String(); got != "28.04 EUR" {
+		t.Errorf("got: %s, want: %s", got, "28.04 EUR")
+	}
+	if len(res.Recipients) != 1201 {
+		t.Fatalf("got: %d recipients, want: %d", len(res.Recipients), 1201)
+	}
+
+	var failed int
+	for _, rr := range res.Recipients {
+		if rr.Err != nil {
+			failed++
+			if rr.Batch != 1 {
+				t.Errorf("recipient %s of batch #%d should not fail", rr.PhoneNumber, rr.Batch)
+			}
+		} else if rr.Response == nil || rr.Response.PhoneNumber != rr.PhoneNumber {
+			t.Errorf("recipient %s not paired with its response: %#v", rr.PhoneNumber, rr.Response)
+		}
+	}
+	if failed != 500 {
+		t.Errorf("got: %d failed recipients, want: %d", failed, 500)
+	}
+}
+
+func TestSendBulkSMSBatchesCurrencyMismatch(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req smspartner.BulkSMS
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		currency := "EUR"
+		if req.SMSList[0].PhoneNumber == "+33600000500" {
+			currency = "USD"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"success": true, "code": 200, "message_id": 1, "cost": %.3f, "currency": %q, "nbSMS": %d}`,
+			0.04*float64(len(req.SMSList)), currency, len(req.SMSList))
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	res, err := cli.SendBulkSMSBatches(context.Background(), &smspartner.BulkSMS{SMSList: testSMSList(600)}, 1)
+	if !errors.Is(err, smspartner.ErrCurrencyMismatch) {
+		t.Fatalf("got: %v, want: %v", err, smspartner.ErrCurrencyMismatch)
+	}
+	if res.NumberOfSMS != 600 || len(res.Recipients) != 600 {
+		t.Errorf("got: %d SMS, %d recipients, want: 600", res.NumberOfSMS, len(res.Recipients))
+	}
+	if got := res.Cost.String(); got != "20 EUR" {
+		t.Errorf("got: %s, want: %s", got, "20 EUR")
+	}
+}
+
+func testSMSList(n int) []*smspartner.SMSPayload {
+	list := make([]*smspartner.SMSPayload, n)
+	for i := range list {
+		list[i] = &smspartner.SMSPayload{
+			PhoneNumber: fmt.Sprintf("+336%08d", i),
+			Message:     "Your message goes here",
+		}
+	}
+	return list
+}
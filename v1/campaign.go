@@ -0,0 +1,270 @@
+package smspartner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// States of a batch in a campaign checkpoint.
+const (
+	BatchPending = "pending"
+	BatchSent    = "sent"
+)
+
+// BatchCheckpoint records the progress of one batch of a campaign.
+type BatchCheckpoint struct {
+	Index     int       `json:"index"`
+	State     string    `json:"state"`
+	MessageID int       `json:"messageId,omitempty"`
+	Cost      Money     `json:"cost"`
+	Excluded  []string  `json:"excluded,omitempty"` // see Suppress
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Checkpoint is the on-disk state of a campaign.
+type Checkpoint struct {
+	CampaignID string             `json:"campaignId"`
+	NbSMS      int                `json:"nbSms"`
+	NbBatches  int                `json:"nbBatches"`
+	Hash       string             `json:"hash"` // of the phone numbers of the SMS list
+	Batches    []*BatchCheckpoint `json:"batches"`
+}
+
+// Done reports whether every batch of the campaign has been sent.
+func (cp *Checkpoint) Done() bool {
+	for _, b := range cp.Batches {
+		if b.State != BatchSent {
+			return false
+		}
+	}
+	return len(cp.Batches) == cp.NbBatches
+}
+
+// BatchInDoubtError is returned when a previous run stopped while a batch was
+// being sent, so that it is unknown whether the API received it.
+// Use Campaign.Resolve once the batch has been checked.
+type BatchInDoubtError struct {
+	CampaignID string
+	Batch      int
+}
+
+func (e *BatchInDoubtError) Error() string {
+	return fmt.Sprintf("campaign %q: batch %d may have been sent, resolve it before resuming", e.CampaignID, e.Batch)
+}
+
+var ErrCampaignMismatch = errors.New("Checkpoint does not match the campaign SMS list")
+
+// Campaign sends a bulk SMS batch after batch and writes a checkpoint file
+// after each batch, so that a campaign stopped midway can be resumed by
+// running it again with the same ID without sending any SMS twice.
+type Campaign struct {
+	ID        string
+	Dir       string // directory of the checkpoint file
+	BulkSMS   *BulkSMS
+	BatchSize int // defaults to MaxBulkSMS
+
+	client *Client
+}
+
+// NewCampaign returns a campaign sending bulksms, checkpointed in dir.
+func (c *Client) NewCampaign(id, dir string, bulksms *BulkSMS) *Campaign {
+	return &Campaign{ID: id, Dir: dir, BulkSMS: bulksms, client: c}
+}
+
+// smsListHash returns the hash of the phone numbers of list, in order.
+func smsListHash(list []*SMSPayload) string {
+	h := sha256.New()
+	for _, sms := range list {
+		if sms != nil {
+			h.Write([]byte(sms.PhoneNumber))
+		}
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (cmp *Campaign) path() (string, error) {
+	if cmp.ID == "" || strings.ContainsAny(cmp.ID, `/\`) || cmp.ID == "." || cmp.ID == ".." {
+		return "", fmt.Errorf("invalid campaign ID %q", cmp.ID)
+	}
+	return filepath.Join(cmp.Dir, cmp.ID+".checkpoint.json"), nil
+}
+
+// Checkpoint returns the saved state of the campaign, or nil if it has never run.
+func (cmp *Campaign) Checkpoint() (*Checkpoint, error) {
+	path, err := cmp.path()
+	if err != nil {
+		return nil, err
+	}
+	blob, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cp := new(Checkpoint)
+	if err := json.Unmarshal(blob, cp); err != nil {
+		return nil, fmt.Errorf("error reading checkpoint %s: %v", path, err)
+	}
+	return cp, nil
+}
+
+func (cmp *Campaign) save(cp *Checkpoint) error {
+	path, err := cmp.path()
+	if err != nil {
+		return err
+	}
+	blob, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, blob)
+}
+
+// writeFileAtomic replaces path with data, so that readers see either the
+// old or the new content even if the process dies while writing.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Resolve settles a batch left in doubt by an interrupted run: a non-zero
+// messageID marks it as sent, zero marks it to be sent again.
+func (cmp *Campaign) Resolve(batch, messageID int) error {
+	cp, err := cmp.Checkpoint()
+	if err != nil {
+		return err
+	}
+	if cp == nil || batch < 0 || batch >= len(cp.Batches) || cp.Batches[batch].State != BatchPending {
+		return fmt.Errorf("campaign %q: batch %d is not in doubt", cmp.ID, batch)
+	}
+
+	if messageID == 0 {
+		cp.Batches = cp.Batches[:batch]
+	} else {
+		b := cp.Batches[batch]
+		b.State, b.MessageID, b.UpdatedAt = BatchSent, messageID, time.Now()
+	}
+	return cmp.save(cp)
+}
+
+// Run sends the batches that have not been sent yet, in order, and returns
+// the checkpoint of the campaign. It stops at the first failed batch.
+func (cmp *Campaign) Run(ctx context.Context) (*Checkpoint, error) {
+	if cmp.BulkSMS == nil || len(cmp.BulkSMS.SMSList) == 0 {
+		return nil, errors.New("SMSList is empty")
+	}
+	if !cmp.client.skipValidation {
+		if err := cmp.BulkSMS.validate(0); err != nil {
+			return nil, err
+		}
+	}
+	hash := smsListHash(cmp.BulkSMS.SMSList)
+	route, err := cmp.client.routeBulkSMS(ctx, cmp.BulkSMS)
+	if err != nil {
+		return nil, err
+	}
+	batches := SplitBulkSMS(routedBulkSMS(cmp.BulkSMS, route), cmp.BatchSize)
+
+	cp, err := cmp.Checkpoint()
+	if err != nil {
+		return nil, err
+	}
+	if cp == nil {
+		cp = &Checkpoint{CampaignID: cmp.ID, NbSMS: len(cmp.BulkSMS.SMSList), NbBatches: len(batches), Hash: hash}
+	}
+	if cp.NbSMS != len(cmp.BulkSMS.SMSList) || cp.NbBatches != len(batches) || cp.Hash != hash {
+		return cp, ErrCampaignMismatch
+	}
+	for _, b := range cp.Batches {
+		if b.State == BatchPending {
+			return cp, &BatchInDoubtError{CampaignID: cmp.ID, Batch: b.Index}
+		}
+	}
+
+	// The cost of the batches left is reserved before sending any of them.
+	start := len(cp.Batches)
+	done, finish, err := cmp.client.guardBatches(ctx, batches[start:])
+	if err != nil {
+		return cp, err
+	}
+	defer finish()
+
+	for i := start; i < len(batches); i++ {
+		if err := ctx.Err(); err != nil {
+			return cp, err
+		}
+
+		b := &BatchCheckpoint{Index: i, State: BatchPending, UpdatedAt: time.Now()}
+		excluded, err := cmp.client.suppressBulkSMS(ctx, batches[i])
+		for _, ex := range excluded {
+			b.Excluded = append(b.Excluded, ex.PhoneNumber)
+		}
+		var allExcluded *AllRecipientsExcludedError
+		if errors.As(err, &allExcluded) {
+			b.State = BatchSent
+		} else if err != nil {
+			return cp, err
+		}
+
+		cp.Batches = append(cp.Batches, b)
+		if err := cmp.save(cp); err != nil {
+			return cp, err
+		}
+		if b.State == BatchSent {
+			continue
+		}
+
+		resp, err := cmp.client.postBulkSMS(ctx, batches[i], func(e *LedgerEntry) { done(i-start, e) })
+		if err != nil {
+			// The request may have reached the API when it was interrupted:
+			// leave the batch pending rather than risk sending it twice.
+			var uerr *url.Error
+			if ctx.Err() != nil || errors.As(err, &uerr) {
+				return cp, err
+			}
+			cp.Batches = cp.Batches[:i]
+			if serr := cmp.save(cp); serr != nil {
+				return cp, serr
+			}
+			return cp, fmt.Errorf("campaign %q: batch %d: %w", cmp.ID, i, err)
+		}
+
+		b.State, b.MessageID, b.Cost, b.UpdatedAt = BatchSent, resp.MessageID, resp.Cost, time.Now()
+		if err := cmp.save(cp); err != nil {
+			return cp, err
+		}
+		if err := cmp.client.recordBulkSMS(batches[i], resp, cmp.ID); err != nil {
+			return cp, err
+		}
+	}
+	return cp, nil
+}
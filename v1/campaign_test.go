@@ -0,0 +1,201 @@
+package smspartner_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+// recordingBulkHandler answers bulk-send requests and counts how many times
+// each phone number has been sent. fail is called for every batch before it
+// is recorded; a non-nil error makes the API reject the batch.
+type recordingBulkHandler struct {
+	mu      sync.Mutex
+	batches int
+	sent    map[string]int
+	fail    func(batch int, r *http.Request) error
+}
+
+func (h *recordingBulkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req smspartner.BulkSMS
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	batch := h.batches
+	h.batches++
+	h.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if h.fail != nil {
+		if err := h.fail(batch, r); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, `{"success": false, "code": 10, "message": %q}`, err.Error())
+			return
+		}
+	}
+
+	h.mu.Lock()
+	for _, sms := range req.SMSList {
+		h.sent[sms.PhoneNumber]++
+	}
+	h.mu.Unlock()
+
+	fmt.Fprintf(w, `{"success": true, "code": 200, "message_id": %d, "cost": %.3f, "nbSMS": %d}`,
+		1000+batch, 0.04*float64(len(req.SMSList)), len(req.SMSList))
+}
+
+func (h *recordingBulkHandler) assertSentOnce(t *testing.T, list []*smspartner.SMSPayload) {
+	t.Helper()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, sms := range list {
+		if n := h.sent[sms.PhoneNumber]; n != 1 {
+			t.Errorf("%s sent %d times, want: 1", sms.PhoneNumber, n)
+		}
+	}
+}
+
+func TestCampaignResumeAfterFailure(t *testing.T) {
+	h := &recordingBulkHandler{sent: map[string]int{}}
+	h.fail = func(batch int, _ *http.Request) error {
+		if batch == 2 {
+			return errors.New("Erreur interne")
+		}
+		return nil
+	}
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	list := testSMSList(550)
+	cmp := cli.NewCampaign("spring-sale", t.TempDir(), &smspartner.BulkSMS{SMSList: list})
+	cmp.BatchSize = 100
+
+	cp, err := cmp.Run(context.Background())
+	if err == nil {
+		t.Fatal("expected the third batch to fail")
+	}
+	if len(cp.Batches) != 2 {
+		t.Fatalf("got: %d batches in checkpoint, want: %d", len(cp.Batches), 2)
+	}
+
+	// restart from scratch with the same campaign ID
+	cmp = cli.NewCampaign("spring-sale", cmp.Dir, &smspartner.BulkSMS{SMSList: list})
+	cmp.BatchSize = 100
+	cp, err = cmp.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cp.Done() {
+		t.Errorf("campaign not done: %#v", cp)
+	}
+	if cp.Batches[0].MessageID != 1000 || cp.Batches[5].MessageID != 1006 {
+		t.Errorf("unexpected message IDs: %d, %d", cp.Batches[0].MessageID, cp.Batches[5].MessageID)
+	}
+	h.assertSentOnce(t, list)
+}
+
+func TestCampaignCrashWhileSending(t *testing.T) {
+	ctx, crash := context.WithCancel(context.Background())
+
+	h := &recordingBulkHandler{sent: map[string]int{}}
+	h.fail = func(batch int, r *http.Request) error {
+		if batch == 1 {
+			// the API accepts the batch but the process dies before
+			// receiving the response
+			crash()
+			<-r.Context().Done()
+		}
+		return nil
+	}
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	list := testSMSList(300)
+	cmp := cli.NewCampaign("reminder", t.TempDir(), &smspartner.BulkSMS{SMSList: list})
+	cmp.BatchSize = 100
+
+	if _, err := cmp.Run(ctx); err == nil {
+		t.Fatal("expected the run to be interrupted")
+	}
+
+	cmp = cli.NewCampaign("reminder", cmp.Dir, &smspartner.BulkSMS{SMSList: list})
+	cmp.BatchSize = 100
+	_, err := cmp.Run(context.Background())
+
+	var doubt *smspartner.BatchInDoubtError
+	if !errors.As(err, &doubt) || doubt.Batch != 1 {
+		t.Fatalf("got: %v, want batch 1 in doubt", err)
+	}
+
+	// the operator checked with GetBulkSMSStatus that batch 1 went out
+	if err := cmp.Resolve(1, 1001); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := cmp.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cp.Done() {
+		t.Errorf("campaign not done: %#v", cp)
+	}
+	h.assertSentOnce(t, list)
+}
+
+func TestCampaignMismatch(t *testing.T) {
+	h := &recordingBulkHandler{sent: map[string]int{}}
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	dir := t.TempDir()
+	if _, err := cli.NewCampaign("news", dir, &smspartner.BulkSMS{SMSList: testSMSList(10)}).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := cli.NewCampaign("news", dir, &smspartner.BulkSMS{SMSList: testSMSList(20)}).Run(context.Background())
+	if err != smspartner.ErrCampaignMismatch {
+		t.Errorf("got: %v, want: %v", err, smspartner.ErrCampaignMismatch)
+	}
+}
+
+func TestCampaignMismatchSameSize(t *testing.T) {
+	h := &recordingBulkHandler{sent: map[string]int{}}
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	dir := t.TempDir()
+	list := testSMSList(10)
+	cmp := cli.NewCampaign("news", dir, &smspartner.BulkSMS{SMSList: list})
+	cmp.BatchSize = 5
+	h.fail = func(batch int, r *http.Request) error {
+		if batch == 1 {
+			return errors.New("Internal error")
+		}
+		return nil
+	}
+	if _, err := cmp.Run(context.Background()); err == nil {
+		t.Fatal("expected the second batch to fail")
+	}
+
+	// Same number of SMS, but not the same recipients.
+	other := testSMSList(10)
+	other[7].PhoneNumber = "+33699999999"
+	cmp = cli.NewCampaign("news", dir, &smspartner.BulkSMS{SMSList: other})
+	cmp.BatchSize = 5
+	if _, err := cmp.Run(context.Background()); err != smspartner.ErrCampaignMismatch {
+		t.Errorf("got: %v, want: %v", err, smspartner.ErrCampaignMismatch)
+	}
+	if h.sent["+33699999999"] != 0 {
+		t.Error("SMS of the other list sent")
+	}
+}
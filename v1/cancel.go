@@ -1,20 +1,48 @@
 package smspartner
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 )
 
-// CancelSMS cancel sending a sent SMS
-func (c *Client) CancelSMS(msgID int) (map[string]interface{}, error) {
-	fullURL := fmt.Sprintf("%s/message-cancel?apiKey=%s&messageId=%d", c.basePath, c.apiKey, msgID)
-	req, err := http.NewRequest("GET", fullURL, nil)
+// CancelResponse is the response of CancelMessage.
+type CancelResponse struct {
+	Success bool   `json:"success"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// CancelMessage cancels sending a scheduled SMS.
+// It returns a *RemoteAPIError if the API refuses to cancel it.
+func (c *Client) CancelMessage(msgID int) (*CancelResponse, error) {
+	return c.cancelMessage(context.Background(), msgID)
+}
+
+func (c *Client) cancelMessage(ctx context.Context, msgID int) (*CancelResponse, error) {
+	res, err := c.cancelSMS(ctx, msgID)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := c.doRequest(req)
+	cr := new(CancelResponse)
+	if err := json.Unmarshal(res, cr); err != nil {
+		return nil, err
+	}
+	if !cr.Success {
+		return nil, &RemoteAPIError{Success: cr.Success, Code: cr.Code, Message: cr.Message}
+	}
+	return cr, nil
+}
+
+// CancelSMS cancel sending a sent SMS
+//
+// Deprecated: use CancelMessage.
+func (c *Client) CancelSMS(msgID int) (map[string]interface{}, error) {
+	res, err := c.cancelSMS(context.Background(), msgID)
 	if err != nil {
 		return nil, err
 	}
@@ -25,3 +53,124 @@ func (c *Client) CancelSMS(msgID int) (map[string]interface{}, error) {
 	}
 	return m, nil
 }
+
+func (c *Client) cancelSMS(ctx context.Context, msgID int) ([]byte, error) {
+	fullURL := fmt.Sprintf("%s/message-cancel?apiKey=%s&messageId=%d", c.basePath, c.apiKey, msgID)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.doRequest(req)
+}
+
+// cancelConcurrency is the number of messages cancelled in parallel by CancelMany.
+const cancelConcurrency = 4
+
+// Error codes of message-cancel.
+const (
+	codeAlreadySent    = 10
+	codeUnknownMessage = 404
+)
+
+// CancelOutcome tells what happened to a message passed to CancelMany.
+type CancelOutcome string
+
+// List of values that CancelOutcome can take.
+const (
+	Cancelled      CancelOutcome = "cancelled"
+	AlreadySent    CancelOutcome = "already sent"
+	UnknownMessage CancelOutcome = "unknown"
+	CancelFailed   CancelOutcome = "failed" // see CancelResult.Err
+)
+
+// CancelResult is the outcome of cancelling one message.
+type CancelResult struct {
+	MessageID int
+	Outcome   CancelOutcome
+	Response  *CancelResponse
+	Err       error
+}
+
+// CancelMany cancels the messages ids, a few at a time, and returns their
+// outcome in the same order. Messages that are no longer scheduled are
+// removed from the store of the Schedules option.
+func (c *Client) CancelMany(ctx context.Context, ids []int) []*CancelResult {
+	results := make([]*CancelResult, len(ids))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, cancelConcurrency)
+	for i, id := range ids {
+		results[i] = &CancelResult{MessageID: id}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Outcome, results[i].Err = CancelFailed, ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(cr *CancelResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			c.cancelOne(ctx, cr)
+		}(results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+func (c *Client) cancelOne(ctx context.Context, cr *CancelResult) {
+	if err := ctx.Err(); err != nil {
+		cr.Outcome, cr.Err = CancelFailed, err
+		return
+	}
+
+	cr.Response, cr.Err = c.cancelMessage(ctx, cr.MessageID)
+	var remAPIErr *RemoteAPIError
+	switch {
+	case cr.Err == nil:
+		cr.Outcome = Cancelled
+	case errors.As(cr.Err, &remAPIErr) && remAPIErr.Code == codeAlreadySent:
+		cr.Outcome = AlreadySent
+	case errors.As(cr.Err, &remAPIErr) && remAPIErr.Code == codeUnknownMessage:
+		cr.Outcome = UnknownMessage
+	default:
+		cr.Outcome = CancelFailed
+		return
+	}
+
+	if c.schedules != nil {
+		if err := c.schedules.Remove(cr.MessageID); err != nil && cr.Err == nil {
+			cr.Err = err
+		}
+	}
+}
+
+// CancelTag cancels the recorded messages sent with tag. See the Schedules option.
+func (c *Client) CancelTag(ctx context.Context, tag string) ([]*CancelResult, error) {
+	return c.cancelRecorded(ctx, func(msg *ScheduledMessage) bool { return msg.Tag == tag })
+}
+
+// CancelCampaign cancels the recorded batches of a campaign. See the Schedules option.
+func (c *Client) CancelCampaign(ctx context.Context, campaignID string) ([]*CancelResult, error) {
+	return c.cancelRecorded(ctx, func(msg *ScheduledMessage) bool { return msg.Campaign == campaignID })
+}
+
+func (c *Client) cancelRecorded(ctx context.Context, match func(*ScheduledMessage) bool) ([]*CancelResult, error) {
+	if c.schedules == nil {
+		return nil, ErrNoScheduleStore
+	}
+	list, err := c.schedules.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for _, msg := range list {
+		if match(msg) {
+			ids = append(ids, msg.MessageID)
+		}
+	}
+	return c.CancelMany(ctx, ids), nil
+}
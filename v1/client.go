@@ -2,7 +2,6 @@ package smspartner
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -18,9 +17,20 @@ const clientDefaultTimeout time.Duration = 10 * time.Second
 var errUnsetAPIKey = fmt.Errorf("could not find %q in your environment", envSMSPartnerAPIKey)
 
 type Client struct {
-	hc       *http.Client
-	basePath string
-	apiKey   string
+	hc             *http.Client
+	basePath       string
+	apiKey         string
+	templates      *TemplateRegistry
+	skipValidation bool
+	stopList       *StopList
+	schedules      ScheduleStore
+	budget         *BudgetGuard
+	ledger         *Ledger
+	subAccount     string
+	router         *Router
+	hlr            *HLRCorrelator
+	lookups        *LookupCache
+	numberFilter   *NumberFilter
 }
 
 // NewClient returns a HTTP client.
@@ -82,6 +92,109 @@ func APIKey(apiKey string) Option {
 	}
 }
 
+// SkipValidation disables the validation of SMS before they are sent,
+// leaving it to the API.
+func SkipValidation() Option {
+	return func(c *Client) error {
+		c.skipValidation = true
+		return nil
+	}
+}
+
+// Suppress removes the numbers of the stop list from the recipients of
+// SendSMS, SendBulkSMS, SendBulkSMSBatches and campaigns. The removed
+// recipients are reported in the Excluded field of the responses.
+func Suppress(stops *StopList) Option {
+	return func(c *Client) error {
+		c.stopList = stops
+		return nil
+	}
+}
+
+// FilterNumbers removes the recipients rejected by filter from SendBulkSMS,
+// SendBulkSMSBatches and campaigns, after the stop list of Suppress. The
+// removed recipients are reported in the Excluded field of the responses.
+// A filter not made with NewNumberFilter looks up numbers with the client.
+// The client keeps a copy of filter.
+func FilterNumbers(filter *NumberFilter) Option {
+	return func(c *Client) error {
+		f := *filter
+		if f.client == nil {
+			f.client = c
+		}
+		c.numberFilter = &f
+		return nil
+	}
+}
+
+// Schedules records in store the messages sent by SendSMS, SendBulkSMS,
+// SendBulkSMSBatches and campaigns with a ScheduledDeliveryDate, so that
+// they can be listed, cancelled or rescheduled. See Upcoming, CancelTag,
+// CancelCampaign and Reschedule.
+func Schedules(store ScheduleStore) Option {
+	return func(c *Client) error {
+		c.schedules = store
+		return nil
+	}
+}
+
+// Budget refuses to send SMS whose estimated cost exceeds the limits of
+// guard, with a *BudgetExceededError, and records their actual cost. The
+// guard must have a ledger, see ErrBudgetLedger.
+func Budget(guard *BudgetGuard) Option {
+	return func(c *Client) error {
+		if guard.Ledger == nil {
+			return ErrBudgetLedger
+		}
+		c.budget = guard
+		return nil
+	}
+}
+
+// RecordCosts records in ledger the cost of the SMS sent and of the numbers
+// verified with VerifyNumber. Clients for sub-accounts share the ledger and
+// record their token, see ForSubAccount.
+func RecordCosts(ledger *Ledger) Option {
+	return func(c *Client) error {
+		c.ledger = ledger
+		return nil
+	}
+}
+
+// Routing chooses with router the gamme of the SMS sent without one. The
+// gamme is set on the SMS and reported in the Route field of the response.
+func Routing(router *Router) Option {
+	return func(c *Client) error {
+		c.router = router
+		return nil
+	}
+}
+
+// HLRResults declares to correlator the campaigns of VerifyNumber, so that
+// their results can be awaited once it receives them at the NotifyURL.
+func HLRResults(correlator *HLRCorrelator) Option {
+	return func(c *Client) error {
+		c.hlr = correlator
+		return nil
+	}
+}
+
+// CacheLookups keeps in cache the lookups of LookupNumbers.
+func CacheLookups(cache *LookupCache) Option {
+	return func(c *Client) error {
+		c.lookups = cache
+		return nil
+	}
+}
+
+// Templates sets the registry used by SendTemplate.
+func Templates(reg *TemplateRegistry) Option {
+	return func(c *Client) error {
+		c.templates = reg
+		return nil
+	}
+}
+
 func (c *Client) parseOptions(opts ...Option) error {
 	for _, option := range opts {
 		err := option(c)
@@ -96,7 +209,7 @@ func (c *Client) doRequest(req *http.Request) ([]byte, error) {
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := c.hc.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %v", err)
+		return nil, fmt.Errorf("error sending request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -113,10 +226,7 @@ func (c *Client) doRequest(req *http.Request) ([]byte, error) {
 		}
 
 		if !remAPIErr.Success && remAPIErr.Code != 200 {
-			if remAPIErr.Message != "" {
-				return nil, errors.New(remAPIErr.Message)
-			}
-			return nil, errors.New(remAPIErr.Error())
+			return nil, remAPIErr
 		}
 
 		if remAPIErr == nil {
@@ -1,6 +1,7 @@
 package smspartner
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -14,13 +15,22 @@ type User struct {
 }
 
 type Credits struct {
-	Balance          string `json:"balance"`
+	Balance          Money  `json:"balance"`
 	CreditHlr        int    `json:"creditHlr"`
 	CreditSMS        int    `json:"creditSms"`
 	CreditSmsLowCost int    `json:"creditSmsLowCost"`
 	Currency         string `json:"currency"`
 	ToSend           int    `json:"toSend"`
-	Solde            string `json:"solde"`
+	Solde            Money  `json:"solde"`
+}
+
+func (cr *Credits) UnmarshalJSON(b []byte) error {
+	type credits Credits
+	if err := json.Unmarshal(b, (*credits)(cr)); err != nil {
+		return err
+	}
+	cr.Balance.Currency, cr.Solde.Currency = cr.Currency, cr.Currency
+	return nil
 }
 
 type CreditsResponse struct {
@@ -33,8 +43,12 @@ type CreditsResponse struct {
 // CheckCredits returns your SMS credit (number of SMS available, based on your
 // own purchases and usage), as well as the number of SMS that are about to be sent.
 func (c *Client) CheckCredits() (*CreditsResponse, error) {
+	return c.checkCredits(context.Background())
+}
+
+func (c *Client) checkCredits(ctx context.Context) (*CreditsResponse, error) {
 	fullURL := fmt.Sprintf("%s/me?apiKey=%s", c.basePath, c.apiKey)
-	req, err := http.NewRequest("GET", fullURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,271 @@
+package smspartner
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMaxSeenReports is the number of reports remembered to detect
+// repeated deliveries when DeliveryReportHandler.MaxSeen is zero.
+const defaultMaxSeenReports = 10000
+
+// DeliveryStatus is the status of an SMS in a DeliveryReport.
+type DeliveryStatus string
+
+// List of values that DeliveryStatus can take.
+const (
+	DeliveryDelivered    DeliveryStatus = "delivered"
+	DeliveryNotDelivered DeliveryStatus = "notDelivered"
+	DeliveryWaiting      DeliveryStatus = "waiting"
+	DeliveryUnknown      DeliveryStatus = "unknown"
+)
+
+// ParseDeliveryStatus returns the status s, as written by the API, e.g.
+// "Delivered" or "Not delivered".
+func ParseDeliveryStatus(s string) DeliveryStatus {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.NewReplacer("_", " ", "-", " ").Replace(s)
+	switch s {
+	case "delivered", "delivrd", "distribué", "1":
+		return DeliveryDelivered
+	case "not delivered", "notdelivered", "undelivered", "undeliv", "failed", "rejected", "expired", "non distribué", "2":
+		return DeliveryNotDelivered
+	case "waiting", "pending", "sent", "enroute", "en attente", "0":
+		return DeliveryWaiting
+	}
+	return DeliveryUnknown
+}
+
+// DeliveryReport is the delivery report (DLR) of an SMS to a recipient, sent
+// by the API to the callback URL of the account.
+type DeliveryReport struct {
+	MessageID   int
+	PhoneNumber string
+	Status      DeliveryStatus
+	RawStatus   string    // as sent by the API
+	Time        time.Time // zero if not sent
+	Cost        Money
+}
+
+// reportField returns the first value of fields set in values.
+func reportField(values url.Values, fields ...string) string {
+	for _, f := range fields {
+		if v := strings.TrimSpace(values.Get(f)); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseReportTime parses the date of a report: a Unix time, or a date in
+// the time zone of the API.
+func parseReportTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, l := range []string{"2006-01-02 15:04:05", "02/01/2006 15:04:05", "02/01/2006 15:04", layout} {
+		if t, err := time.ParseInLocation(l, s, apiLocation); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+func deliveryReportFromValues(values url.Values) (*DeliveryReport, error) {
+	dr := &DeliveryReport{
+		PhoneNumber: reportField(values, "phoneNumber", "phone", "number", "to"),
+		RawStatus:   reportField(values, "status", "statut", "dlr"),
+	}
+	if dr.PhoneNumber == "" {
+		return nil, fmt.Errorf("missing phone number")
+	}
+	dr.Status = ParseDeliveryStatus(dr.RawStatus)
+
+	id := reportField(values, "messageId", "msgId", "message_id", "msg_id")
+	if id == "" {
+		return nil, fmt.Errorf("missing message ID")
+	}
+	var err error
+	if dr.MessageID, err = strconv.Atoi(id); err != nil {
+		return nil, fmt.Errorf("invalid message ID %q", id)
+	}
+	if dr.Time, err = parseReportTime(reportField(values, "date", "timestamp", "deliveryDate", "receptionDate")); err != nil {
+		return nil, err
+	}
+	if cost := reportField(values, "cost", "price"); cost != "" {
+		if dr.Cost, err = ParseMoney(cost, reportField(values, "currency")); err != nil {
+			return nil, err
+		}
+	}
+	return dr, nil
+}
+
+// jsonValues returns the fields of a JSON object as form values.
+func jsonValues(obj map[string]interface{}) url.Values {
+	values := url.Values{}
+	for k, v := range obj {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			values.Set(k, v)
+		default:
+			values.Set(k, fmt.Sprint(v))
+		}
+	}
+	return values
+}
+
+// ParseDeliveryReports returns the reports sent in a DLR callback: a JSON
+// object, or list of objects, or form values in the query string or body.
+func ParseDeliveryReports(r *http.Request) ([]*DeliveryReport, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackSize))
+	if err != nil {
+		return nil, err
+	}
+	body = bytes.TrimSpace(body)
+
+	var forms []url.Values
+	if len(body) > 0 && (body[0] == '{' || body[0] == '[') {
+		if body[0] == '{' {
+			body = append(append([]byte{'['}, body...), ']')
+		}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var objs []map[string]interface{}
+		if err := dec.Decode(&objs); err != nil {
+			return nil, err
+		}
+		for _, obj := range objs {
+			forms = append(forms, jsonValues(obj))
+		}
+	} else {
+		form := r.URL.Query()
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range values {
+			form[k] = append(form[k], v...)
+		}
+		forms = append(forms, form)
+	}
+
+	reports := make([]*DeliveryReport, 0, len(forms))
+	for _, form := range forms {
+		dr, err := deliveryReportFromValues(form)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delivery report: %v", err)
+		}
+		reports = append(reports, dr)
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("no delivery report in callback")
+	}
+	return reports, nil
+}
+
+type reportKey struct {
+	messageID   int
+	phoneNumber string
+	status      DeliveryStatus
+}
+
+// DeliveryReportHandler is an http.Handler receiving the DLR callbacks of the
+// API. Each report is passed to OnReport, if set, and sent on Reports, if
+// set, unless the same status of the same SMS has already been received.
+type DeliveryReportHandler struct {
+	OnReport func(*DeliveryReport)
+	Reports  chan<- *DeliveryReport
+	MaxSeen  int // reports remembered to ignore repeated deliveries, defaults to 10000
+
+	mu    sync.Mutex
+	seen  map[reportKey]*list.Element
+	order *list.List // of reportKey, the oldest first
+}
+
+// NewDeliveryReportHandler returns a handler calling onReport.
+func NewDeliveryReportHandler(onReport func(*DeliveryReport)) *DeliveryReportHandler {
+	return &DeliveryReportHandler{OnReport: onReport}
+}
+
+func (h *DeliveryReportHandler) maxSeen() int {
+	if h.MaxSeen <= 0 {
+		return defaultMaxSeenReports
+	}
+	return h.MaxSeen
+}
+
+// mark records k as seen and reports whether it was new.
+func (h *DeliveryReportHandler) mark(k reportKey) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.seen == nil {
+		h.seen, h.order = map[reportKey]*list.Element{}, list.New()
+	}
+	if _, ok := h.seen[k]; ok {
+		return false
+	}
+	h.seen[k] = h.order.PushBack(k)
+	for h.order.Len() > h.maxSeen() {
+		oldest := h.order.Front()
+		h.order.Remove(oldest)
+		delete(h.seen, oldest.Value.(reportKey))
+	}
+	return true
+}
+
+// unmark forgets k, so that the report is accepted when delivered again.
+func (h *DeliveryReportHandler) unmark(k reportKey) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if el, ok := h.seen[k]; ok {
+		h.order.Remove(el)
+		delete(h.seen, k)
+	}
+}
+
+// ServeHTTP receives the DLR callbacks, see ParseDeliveryReports. When the
+// request is cancelled before a report could be sent on Reports, it answers
+// 503 so that the API delivers it again.
+func (h *DeliveryReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reports, err := ParseDeliveryReports(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, dr := range reports {
+		k := reportKey{dr.MessageID, dr.PhoneNumber, dr.Status}
+		if !h.mark(k) {
+			continue
+		}
+		if h.Reports != nil {
+			select {
+			case h.Reports <- dr:
+			case <-r.Context().Done():
+				h.unmark(k)
+				http.Error(w, "delivery report not dispatched", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		if h.OnReport != nil {
+			h.OnReport(dr)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,93 @@
+package smspartner_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+func TestDeliveryReportHandler(t *testing.T) {
+	var reports []*smspartner.DeliveryReport
+	h := smspartner.NewDeliveryReportHandler(func(dr *smspartner.DeliveryReport) {
+		reports = append(reports, dr)
+	})
+
+	query, err := fixture("dlr_query.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := fixture("dlr.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/dlr?"+strings.TrimSpace(string(query)), nil),
+		httptest.NewRequest("POST", "/dlr", bytes.NewReader(body)),
+		// Delivered again.
+		httptest.NewRequest("POST", "/dlr", bytes.NewReader(body)),
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status: %d, want: %d", w.Code, http.StatusOK)
+		}
+	}
+
+	if len(reports) != 2 {
+		t.Fatalf("got %d reports, want: 2", len(reports))
+	}
+	want := time.Date(2018, 8, 18, 20, 0, 12, 0, time.UTC).Add(-2 * time.Hour)
+	dr := reports[0]
+	if dr.MessageID != 2270142 || dr.PhoneNumber != "+33620123456" || dr.Status != smspartner.DeliveryDelivered || !dr.Time.Equal(want) || dr.Cost.String() != "0.04 EUR" {
+		t.Errorf("unexpected report: %+v", dr)
+	}
+	dr = reports[1]
+	if dr.PhoneNumber != "+33621123456" || dr.Status != smspartner.DeliveryNotDelivered || !dr.Time.Equal(want) || dr.RawStatus != "Not delivered" {
+		t.Errorf("unexpected report: %+v", dr)
+	}
+}
+
+func TestDeliveryReportHandlerChannel(t *testing.T) {
+	ch := make(chan *smspartner.DeliveryReport)
+	h := &smspartner.DeliveryReportHandler{Reports: ch}
+	form := "messageId=1&phoneNumber=%2B33620123456&statut=Waiting"
+
+	// Nobody receives: the report is refused and accepted when delivered again.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("POST", "/dlr", strings.NewReader(form)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got status: %d, want: %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/dlr", strings.NewReader(form)))
+	select {
+	case dr := <-ch:
+		if dr.MessageID != 1 || dr.Status != smspartner.DeliveryWaiting {
+			t.Errorf("unexpected report: %+v", dr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no report received")
+	}
+}
+
+func TestParseDeliveryReportsWithError(t *testing.T) {
+	for _, body := range []string{
+		`{"phoneNumber": "+33620123456", "statut": "Delivered"}`,
+		`messageId=abc&phoneNumber=%2B33620123456`,
+		`messageId=1&phoneNumber=%2B33620123456&date=tomorrow`,
+	} {
+		if _, err := smspartner.ParseDeliveryReports(httptest.NewRequest("POST", "/dlr", strings.NewReader(body))); err == nil {
+			t.Errorf("%s: expected an error", body)
+		}
+	}
+}
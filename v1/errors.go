@@ -5,7 +5,7 @@ import "fmt"
 // RemoteAPIError is used to handle API error response
 // if there are errors (Success == false && Code != 200) the client library
 // returns a summary of all errors (e.g., "one error (and 2 other errors)").
-// TODO:(hoflish) give client-lib users an option to get verbose errors
+// Use errors.As to get every validation error.
 type RemoteAPIError struct {
 	Success bool               `json:"success,omitempty"`
 	Code    int                `json:"code,omitempty"`
@@ -19,6 +19,10 @@ type ValidationError struct {
 }
 
 func (r *RemoteAPIError) Error() string {
+	if r.Message != "" {
+		return r.Message
+	}
+
 	msg, n := "", 0
 	for _, e := range r.VError {
 		if e != nil {
@@ -0,0 +1,345 @@
+package smspartner
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCallbackSize is the maximum size of the body of a callback.
+const maxCallbackSize = 1 << 20
+
+// HLRStatus is the status of a number returned by an HLR verification.
+type HLRStatus string
+
+// List of values that HLRStatus can take.
+const (
+	HLRValid   HLRStatus = "valid"
+	HLRInvalid HLRStatus = "invalid"
+	HLRUnknown HLRStatus = "unknown"
+)
+
+// flag is a boolean the API sends as true, 1 or "1".
+type flag bool
+
+func (f *flag) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	*f = flag(parseFlag(s))
+	return nil
+}
+
+func parseFlag(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes", "oui":
+		return true
+	}
+	return false
+}
+
+// HLRResult is the result of the HLR verification of a number, sent by the
+// API to the NotifyURL of the NumberVerificationRequest.
+type HLRResult struct {
+	CampaignID      string    `json:"campaign_id"`
+	PhoneNumber     string    `json:"phoneNumber"`
+	Status          HLRStatus `json:"status"`
+	Country         string    `json:"countryCode"`
+	Network         string    `json:"network"`
+	MCCMNC          string    `json:"mccmnc"`
+	OriginalNetwork string    `json:"originalNetwork"`
+	Ported          bool      `json:"-"`
+	Roaming         bool      `json:"-"`
+	Message         string    `json:"message"`
+}
+
+func (r *HLRResult) UnmarshalJSON(b []byte) error {
+	type result HLRResult
+	var v struct {
+		*result
+		Ported  flag `json:"ported"`
+		Roaming flag `json:"roaming"`
+	}
+	v.result = (*result)(r)
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	r.Status = HLRStatus(strings.ToLower(string(r.Status)))
+	r.Ported, r.Roaming = bool(v.Ported), bool(v.Roaming)
+	return nil
+}
+
+func hlrResultFromForm(form url.Values) *HLRResult {
+	campaignID := form.Get("campaign_id")
+	if campaignID == "" {
+		campaignID = form.Get("campaignId")
+	}
+	return &HLRResult{
+		CampaignID:      campaignID,
+		PhoneNumber:     form.Get("phoneNumber"),
+		Status:          HLRStatus(strings.ToLower(form.Get("status"))),
+		Country:         form.Get("countryCode"),
+		Network:         form.Get("network"),
+		MCCMNC:          form.Get("mccmnc"),
+		OriginalNetwork: form.Get("originalNetwork"),
+		Ported:          parseFlag(form.Get("ported")),
+		Roaming:         parseFlag(form.Get("roaming")),
+		Message:         form.Get("message"),
+	}
+}
+
+// ParseHLRCallback returns the results sent in an HLR callback: a JSON
+// object, or list of objects, or form values.
+func ParseHLRCallback(r *http.Request) ([]*HLRResult, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackSize))
+	if err != nil {
+		return nil, err
+	}
+	body = bytes.TrimSpace(body)
+
+	var results []*HLRResult
+	switch {
+	case len(body) > 0 && body[0] == '[':
+		if err := json.Unmarshal(body, &results); err != nil {
+			return nil, err
+		}
+	case len(body) > 0 && body[0] == '{':
+		res := new(HLRResult)
+		if err := json.Unmarshal(body, res); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	default:
+		form := r.URL.Query()
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range values {
+			form[k] = append(form[k], v...)
+		}
+		results = append(results, hlrResultFromForm(form))
+	}
+
+	n := 0
+	for _, res := range results {
+		if res != nil && res.PhoneNumber != "" {
+			results[n] = res
+			n++
+		}
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("no HLR result in callback")
+	}
+	return results[:n], nil
+}
+
+// Defaults of the HLRCorrelator fields.
+const (
+	defaultHLRMaxUnknown = 100
+	defaultHLRTTL        = time.Hour
+)
+
+type hlrCampaign struct {
+	id         string
+	expected   int  // 0 until Expect is called
+	registered bool // by Expect or Await
+	waiters    int  // calls of Await in progress
+	created    time.Time
+	results    []*HLRResult
+	numbers    map[string]bool
+	done       chan struct{}
+	el         *list.Element
+}
+
+func (cp *hlrCampaign) complete() bool {
+	return cp.expected > 0 && len(cp.results) >= cp.expected
+}
+
+// HLRCorrelator matches the HLR callbacks it receives, as an http.Handler,
+// to the campaigns of VerifyNumber, so that their results can be awaited.
+// The results received before their campaign is expected are kept for at
+// most MaxUnknown campaigns, the oldest being dropped first. Every campaign
+// is forgotten TTL after its first result or its registration, unless it
+// is being awaited. See the HLRResults option.
+type HLRCorrelator struct {
+	// OnResult, if set, is called with every new result.
+	OnResult func(*HLRResult)
+
+	MaxUnknown int           // campaigns received before Expect or Await, defaults to 100
+	TTL        time.Duration // defaults to 1 hour
+
+	mu        sync.Mutex
+	campaigns map[string]*hlrCampaign
+	order     *list.List // of *hlrCampaign, the oldest first
+	unknown   int        // campaigns not registered
+}
+
+// NewHLRCorrelator returns a correlator without campaigns.
+func NewHLRCorrelator() *HLRCorrelator {
+	return &HLRCorrelator{campaigns: map[string]*hlrCampaign{}, order: list.New()}
+}
+
+func (h *HLRCorrelator) maxUnknown() int {
+	if h.MaxUnknown <= 0 {
+		return defaultHLRMaxUnknown
+	}
+	return h.MaxUnknown
+}
+
+func (h *HLRCorrelator) ttl() time.Duration {
+	if h.TTL <= 0 {
+		return defaultHLRTTL
+	}
+	return h.TTL
+}
+
+// campaign returns campaignID, creating it if needed. Registering it keeps
+// it when too many unknown campaigns are received.
+func (h *HLRCorrelator) campaign(campaignID string, register bool) *hlrCampaign {
+	if h.campaigns == nil {
+		h.campaigns, h.order = map[string]*hlrCampaign{}, list.New()
+	}
+	now := time.Now()
+	h.expire(now)
+
+	cp, ok := h.campaigns[campaignID]
+	if !ok {
+		cp = &hlrCampaign{id: campaignID, created: now, numbers: map[string]bool{}, done: make(chan struct{})}
+		cp.el = h.order.PushBack(cp)
+		h.campaigns[campaignID] = cp
+		h.unknown++
+	}
+	if register && !cp.registered {
+		cp.registered = true
+		h.unknown--
+	}
+	for h.unknown > h.maxUnknown() {
+		h.dropOldestUnknown()
+	}
+	return cp
+}
+
+// expire forgets the campaigns older than TTL that are not awaited.
+func (h *HLRCorrelator) expire(now time.Time) {
+	for el := h.order.Front(); el != nil; el = h.order.Front() {
+		cp := el.Value.(*hlrCampaign)
+		if now.Sub(cp.created) < h.ttl() {
+			return
+		}
+		if cp.waiters > 0 {
+			cp.created = now
+			h.order.MoveToBack(el)
+			continue
+		}
+		h.remove(cp)
+	}
+}
+
+func (h *HLRCorrelator) dropOldestUnknown() {
+	for el := h.order.Front(); el != nil; el = el.Next() {
+		if cp := el.Value.(*hlrCampaign); !cp.registered {
+			h.remove(cp)
+			return
+		}
+	}
+}
+
+func (h *HLRCorrelator) remove(cp *hlrCampaign) {
+	if h.campaigns[cp.id] != cp {
+		return
+	}
+	h.order.Remove(cp.el)
+	delete(h.campaigns, cp.id)
+	if !cp.registered {
+		h.unknown--
+	}
+}
+
+// Expect declares that campaignID verifies n numbers, the Number of the
+// NumberVerificationResponse.
+func (h *HLRCorrelator) Expect(campaignID string, n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cp := h.campaign(campaignID, true)
+	if cp.complete() {
+		return
+	}
+	cp.expected = n
+	if cp.complete() {
+		close(cp.done)
+	}
+}
+
+// Add adds res to the results of its campaign. The repeated results of a
+// number are ignored.
+func (h *HLRCorrelator) Add(res *HLRResult) {
+	h.mu.Lock()
+	cp := h.campaign(res.CampaignID, false)
+	if cp.numbers[res.PhoneNumber] {
+		h.mu.Unlock()
+		return
+	}
+	cp.numbers[res.PhoneNumber] = true
+	cp.results = append(cp.results, res)
+	if cp.expected > 0 && len(cp.results) == cp.expected {
+		close(cp.done)
+	}
+	onResult := h.OnResult
+	h.mu.Unlock()
+
+	if onResult != nil {
+		onResult(res)
+	}
+}
+
+// Await waits until all the results of campaignID are received. If ctx is
+// done first, it returns the results received so far with the error of
+// ctx. The results are kept until Forget is called or TTL has elapsed, so
+// that Await can be called again.
+func (h *HLRCorrelator) Await(ctx context.Context, campaignID string) ([]*HLRResult, error) {
+	h.mu.Lock()
+	cp := h.campaign(campaignID, true)
+	cp.waiters++
+	h.mu.Unlock()
+
+	var err error
+	select {
+	case <-cp.done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cp.waiters--
+	return append([]*HLRResult(nil), cp.results...), err
+}
+
+// Forget drops the results of campaignID, e.g. once it is abandoned.
+func (h *HLRCorrelator) Forget(campaignID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if cp, ok := h.campaigns[campaignID]; ok {
+		h.remove(cp)
+	}
+}
+
+// ServeHTTP receives the HLR callbacks, see ParseHLRCallback.
+func (h *HLRCorrelator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	results, err := ParseHLRCallback(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, res := range results {
+		h.Add(res)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,132 @@
+package smspartner_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+func TestHLRCorrelator(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		b, err := fixture("verify_number.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+	corr := smspartner.NewHLRCorrelator()
+	cli, teardown := testingHTTPClient(t, h, smspartner.HLRResults(corr))
+	defer teardown()
+
+	res, err := cli.VerifyNumber(&smspartner.NumberVerificationRequest{
+		PhoneNumbers: "+33620123456",
+		NotifyURL:    "https://example.com/hlr",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callback, err := fixture("hlr_notify.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The callback is delivered twice.
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		corr.ServeHTTP(w, httptest.NewRequest("POST", "/hlr", strings.NewReader(string(callback))))
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("got status: %d, want: %d", w.Code, http.StatusNoContent)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	results, err := corr.Await(ctx, res.CampaignID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want: 1", len(results))
+	}
+	got := results[0]
+	if got.Status != smspartner.HLRValid || got.Country != "FR" || got.Network != "Orange" || !got.Ported || got.Roaming {
+		t.Errorf("unexpected result: %+v", got)
+	}
+}
+
+func TestHLRCorrelatorTimeout(t *testing.T) {
+	corr := smspartner.NewHLRCorrelator()
+	corr.Expect("HLR1", 2)
+
+	req := httptest.NewRequest("POST", "/hlr?campaign_id=HLR1", strings.NewReader("phoneNumber=%2B33620123456&status=invalid&roaming=true"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	corr.ServeHTTP(w, req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	results, err := corr.Await(ctx, "HLR1")
+	if err != context.DeadlineExceeded {
+		t.Fatalf("got error: %v, want: %v", err, context.DeadlineExceeded)
+	}
+	if len(results) != 1 || results[0].Status != smspartner.HLRInvalid || !results[0].Roaming {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
+
+func TestHLRCorrelatorEarlyResults(t *testing.T) {
+	corr := smspartner.NewHLRCorrelator()
+	corr.MaxUnknown = 2
+	// The callbacks arrive before VerifyNumber returns the campaign IDs.
+	for i := 0; i < 4; i++ {
+		corr.Add(&smspartner.HLRResult{CampaignID: fmt.Sprintf("HLR%d", i), PhoneNumber: "+33620123456", Status: smspartner.HLRValid})
+	}
+	corr.Expect("HLR3", 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		results, err := corr.Await(ctx, "HLR3")
+		if err != nil || len(results) != 1 {
+			t.Fatalf("Await #%d: got: %v, %+v, want 1 result", i+1, err, results)
+		}
+	}
+
+	// the oldest unknown campaigns are dropped
+	corr.Expect("HLR0", 1)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if results, err := corr.Await(ctx, "HLR0"); err != context.DeadlineExceeded || len(results) != 0 {
+		t.Errorf("got: %v, %+v, want the results of HLR0 dropped", err, results)
+	}
+}
+
+func TestHLRCorrelatorTTL(t *testing.T) {
+	corr := smspartner.NewHLRCorrelator()
+	corr.TTL = time.Millisecond
+	corr.Expect("HLR1", 1)
+	corr.Add(&smspartner.HLRResult{CampaignID: "HLR1", PhoneNumber: "+33620123456", Status: smspartner.HLRValid})
+
+	time.Sleep(5 * time.Millisecond)
+	corr.Add(&smspartner.HLRResult{CampaignID: "HLR2", PhoneNumber: "+33620123456", Status: smspartner.HLRValid})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if results, err := corr.Await(ctx, "HLR1"); err != context.DeadlineExceeded || len(results) != 0 {
+		t.Errorf("got: %v, %+v, want HLR1 forgotten", err, results)
+	}
+}
+
+func TestParseHLRCallbackWithError(t *testing.T) {
+	req := httptest.NewRequest("POST", "/hlr", strings.NewReader(`{"status": "valid"}`))
+	if _, err := smspartner.ParseHLRCallback(req); err == nil {
+		t.Error("expected an error for a callback without phone number")
+	}
+}
@@ -0,0 +1,532 @@
+package smspartner
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LedgerEntry is a billed operation.
+type LedgerEntry struct {
+	Time       time.Time        `json:"time"`
+	Endpoint   string           `json:"endpoint"` // e.g. "send", "bulk-send" or "hlr/notify"
+	MessageID  int              `json:"messageId,omitempty"`
+	CampaignID string           `json:"campaignId,omitempty"`
+	Recipients []string         `json:"recipients,omitempty"`
+	Segments   int              `json:"segments,omitempty"` // number of SMS billed
+	Cost       Money            `json:"cost"`
+	SubAccount string           `json:"subAccount,omitempty"` // token, see ForSubAccount
+	Gamme      Gamme            `json:"gamme,omitempty"`
+	Tag        string           `json:"tag,omitempty"`
+	Countries  []*LedgerCountry `json:"countries,omitempty"`
+}
+
+// LedgerCountry is the part of a LedgerEntry billed for the recipients of a
+// country. When the API does not return the cost of each recipient, the
+// cost is split in proportion to the number of recipients.
+type LedgerCountry struct {
+	Country    string `json:"country"` // empty if unknown
+	Recipients int    `json:"recipients"`
+	Segments   int    `json:"segments,omitempty"`
+	Cost       Money  `json:"cost"`
+}
+
+// Ledger is an append-only JSON lines file of billed operations.
+// See the RecordCosts option.
+//
+// The costs of each day are read once and then kept up to date by Append,
+// so the file must not be appended to by another Ledger while it is used.
+type Ledger struct {
+	Path string
+
+	// OnError is called when a client could not record an operation.
+	OnError func(error)
+
+	mu   sync.Mutex
+	days map[string]*ledgerDay // by day in the time zone of the API, nil until read
+}
+
+// ledgerDay is the cost of the operations of a day.
+type ledgerDay struct {
+	cost Money
+	err  error // set if the costs are in different currencies
+}
+
+func (d *ledgerDay) add(cost Money) {
+	if d.err == nil {
+		d.cost, d.err = d.cost.Add(cost)
+	}
+}
+
+func ledgerDayKey(t time.Time) string {
+	return t.In(apiLocation).Format("2006-01-02")
+}
+
+// NewLedger returns the ledger kept in the file path.
+func NewLedger(path string) *Ledger {
+	return &Ledger{Path: path}
+}
+
+// Append writes e at the end of the ledger.
+func (l *Ledger) Append(e *LedgerEntry) error {
+	blob, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(blob, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if l.days != nil {
+		l.day(e.Time).add(e.Cost)
+	}
+	return nil
+}
+
+// day returns the costs of the day of t, creating it if needed.
+func (l *Ledger) day(t time.Time) *ledgerDay {
+	key := ledgerDayKey(t)
+	d, ok := l.days[key]
+	if !ok {
+		d = &ledgerDay{}
+		l.days[key] = d
+	}
+	return d
+}
+
+// Entries returns the entries of the ledger in the order they were written.
+// A missing file is an empty ledger.
+func (l *Ledger) Entries() ([]*LedgerEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.entries()
+}
+
+func (l *Ledger) entries() ([]*LedgerEntry, error) {
+	f, err := os.Open(l.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*LedgerEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		e := new(LedgerEntry)
+		if err := json.Unmarshal(sc.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("error reading ledger %s, line %d: %v", l.Path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
+
+// Spent returns the total cost of the operations made in [from, to). When
+// from and to are midnights in the time zone of the API, or zero, it sums
+// the costs of the days kept in memory rather than reading the file.
+func (l *Ledger) Spent(from, to time.Time) (Money, error) {
+	if isMidnight(from) && isMidnight(to) {
+		return l.spentDays(from, to)
+	}
+	entries, err := l.between(from, to)
+	if err != nil {
+		return Money{}, err
+	}
+
+	var total Money
+	for _, e := range entries {
+		if total, err = total.Add(e.Cost); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// isMidnight reports whether t is a midnight in the time zone of the API, or
+// zero.
+func isMidnight(t time.Time) bool {
+	if t.IsZero() {
+		return true
+	}
+	t = t.In(apiLocation)
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
+
+// spentDays returns the total cost of the days in [from, to), reading the
+// ledger the first time.
+func (l *Ledger) spentDays(from, to time.Time) (Money, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.days == nil {
+		entries, err := l.entries()
+		if err != nil {
+			return Money{}, err
+		}
+		l.days = map[string]*ledgerDay{}
+		for _, e := range entries {
+			l.day(e.Time).add(e.Cost)
+		}
+	}
+
+	var first, last string
+	if !from.IsZero() {
+		first = ledgerDayKey(from)
+	}
+	if !to.IsZero() {
+		last = ledgerDayKey(to)
+	}
+	var total Money
+	for key, d := range l.days {
+		if key < first || (last != "" && key >= last) {
+			continue
+		}
+		if d.err != nil {
+			return Money{}, d.err
+		}
+		var err error
+		if total, err = total.Add(d.cost); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// between returns the entries of the operations made in [from, to), a zero
+// time leaving the range open.
+func (l *Ledger) between(from, to time.Time) ([]*LedgerEntry, error) {
+	entries, err := l.Entries()
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	for _, e := range entries {
+		if e.Time.Before(from) || (!to.IsZero() && !e.Time.Before(to)) {
+			continue
+		}
+		entries[n] = e
+		n++
+	}
+	return entries[:n], nil
+}
+
+// LedgerGroup is the key by which Totals groups the entries of a ledger.
+type LedgerGroup string
+
+// List of values that LedgerGroup can take.
+const (
+	GroupDay        LedgerGroup = "day" // in the time zone of the API
+	GroupSubAccount LedgerGroup = "subAccount"
+	GroupCountry    LedgerGroup = "country"
+	GroupGamme      LedgerGroup = "gamme"
+	GroupTag        LedgerGroup = "tag"
+)
+
+// LedgerTotal sums the operations of a ledger with the same key and currency.
+type LedgerTotal struct {
+	Key        string
+	Currency   string
+	Operations int
+	Recipients int
+	Segments   int
+	Cost       Money
+}
+
+func (t *LedgerTotal) add(operations, recipients, segments int, cost Money) error {
+	t.Operations += operations
+	t.Recipients += recipients
+	t.Segments += segments
+	var err error
+	t.Cost, err = t.Cost.Add(cost)
+	return err
+}
+
+// Totals sums the operations made in [from, to) by group, ordered by key
+// and currency. A zero time leaves the range open. The key of the entries
+// without a sub-account, gamme or tag is empty.
+func (l *Ledger) Totals(group LedgerGroup, from, to time.Time) ([]*LedgerTotal, error) {
+	entries, err := l.between(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := map[[2]string]*LedgerTotal{}
+	total := func(key, currency string) *LedgerTotal {
+		t, ok := totals[[2]string{key, currency}]
+		if !ok {
+			t = &LedgerTotal{Key: key, Currency: currency, Cost: Money{Currency: currency}}
+			totals[[2]string{key, currency}] = t
+		}
+		return t
+	}
+	for _, e := range entries {
+		currency := e.Cost.Currency
+		var key string
+		switch group {
+		case GroupDay:
+			key = e.Time.In(apiLocation).Format("2006-01-02")
+		case GroupSubAccount:
+			key = e.SubAccount
+		case GroupCountry:
+			if len(e.Countries) == 0 {
+				if err := total("", currency).add(1, len(e.Recipients), e.Segments, e.Cost); err != nil {
+					return nil, err
+				}
+			}
+			// An operation counts once for each of its countries.
+			for _, c := range e.Countries {
+				if err := total(c.Country, currency).add(1, c.Recipients, c.Segments, c.Cost); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		case GroupGamme:
+			key = gammeName(e.Gamme)
+		case GroupTag:
+			key = e.Tag
+		default:
+			return nil, fmt.Errorf("unknown ledger group %q", group)
+		}
+		if err := total(key, currency).add(1, len(e.Recipients), e.Segments, e.Cost); err != nil {
+			return nil, err
+		}
+	}
+
+	list := make([]*LedgerTotal, 0, len(totals))
+	for _, t := range totals {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Key != list[j].Key {
+			return list[i].Key < list[j].Key
+		}
+		return list[i].Currency < list[j].Currency
+	})
+	return list, nil
+}
+
+func gammeName(g Gamme) string {
+	switch g {
+	case Premium:
+		return "premium"
+	case LowCost:
+		return "lowcost"
+	case 0:
+		return ""
+	}
+	return strconv.Itoa(int(g))
+}
+
+// WriteCSV writes the operations made in [from, to) to w, one per line after
+// a header. A zero time leaves the range open.
+func (l *Ledger) WriteCSV(w io.Writer, from, to time.Time) error {
+	entries, err := l.between(from, to)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "endpoint", "message_id", "campaign_id", "sub_account", "tag", "gamme", "recipients", "segments", "cost", "currency"})
+	for _, e := range entries {
+		var messageID string
+		if e.MessageID != 0 {
+			messageID = strconv.Itoa(e.MessageID)
+		}
+		cw.Write([]string{
+			e.Time.In(apiLocation).Format(time.RFC3339),
+			e.Endpoint,
+			messageID,
+			e.CampaignID,
+			e.SubAccount,
+			e.Tag,
+			gammeName(e.Gamme),
+			strconv.Itoa(len(e.Recipients)),
+			strconv.Itoa(e.Segments),
+			e.Cost.Amount(),
+			e.Cost.Currency,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteTotalsCSV writes the totals of the operations made in [from, to) by
+// group to w, see Totals.
+func (l *Ledger) WriteTotalsCSV(w io.Writer, group LedgerGroup, from, to time.Time) error {
+	totals, err := l.Totals(group, from, to)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{string(group), "operations", "recipients", "segments", "cost", "currency"})
+	for _, t := range totals {
+		cw.Write([]string{
+			t.Key,
+			strconv.Itoa(t.Operations),
+			strconv.Itoa(t.Recipients),
+			strconv.Itoa(t.Segments),
+			t.Cost.Amount(),
+			t.Currency,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// splitPhoneNumbers returns the numbers of a comma separated list.
+func splitPhoneNumbers(phoneNumbers string) []string {
+	var numbers []string
+	for _, number := range strings.Split(phoneNumbers, ",") {
+		if number = strings.TrimSpace(number); number != "" {
+			numbers = append(numbers, number)
+		}
+	}
+	return numbers
+}
+
+// splitCost splits the segments and cost of an operation between the
+// countries of recipients, in proportion to their number of recipients.
+func splitCost(recipients []string, segments int, cost Money) []*LedgerCountry {
+	var countries []*LedgerCountry
+	byCountry := map[string]*LedgerCountry{}
+	for _, number := range recipients {
+		country := recipientCountry(number, "FR")
+		c, ok := byCountry[country]
+		if !ok {
+			c = &LedgerCountry{Country: country}
+			byCountry[country] = c
+			countries = append(countries, c)
+		}
+		c.Recipients++
+	}
+
+	// The last country gets the remainders of the divisions.
+	n := int64(len(recipients))
+	restSegments, restCost := segments, cost.Micros()
+	for i, c := range countries {
+		if i == len(countries)-1 {
+			c.Segments, c.Cost = restSegments, MoneyFromMicros(restCost, cost.Currency)
+			break
+		}
+		c.Segments = int(int64(segments) * int64(c.Recipients) / n)
+		c.Cost = MoneyFromMicros(cost.Micros()*int64(c.Recipients)/n, cost.Currency)
+		restSegments -= c.Segments
+		restCost -= c.Cost.Micros()
+	}
+	return countries
+}
+
+func defaultGamme(g Gamme) Gamme {
+	if g == 0 {
+		return Premium
+	}
+	return g
+}
+
+func smsLedgerEntry(sms *SMS, resp *SMSResponse) *LedgerEntry {
+	recipients := splitPhoneNumbers(sms.PhoneNumbers)
+	return &LedgerEntry{
+		Endpoint:   "send",
+		MessageID:  resp.MessageID,
+		Recipients: recipients,
+		Segments:   resp.NumberOfSMS,
+		Cost:       resp.Cost,
+		Gamme:      defaultGamme(sms.Gamme),
+		Tag:        sms.Tag,
+		Countries:  splitCost(recipients, resp.NumberOfSMS, resp.Cost),
+	}
+}
+
+func bulkSMSLedgerEntry(bulksms *BulkSMS, resp *BulkSMSResponse) *LedgerEntry {
+	recipients := make([]string, 0, len(bulksms.SMSList))
+	for _, sms := range bulksms.SMSList {
+		if sms != nil {
+			recipients = append(recipients, sms.PhoneNumber)
+		}
+	}
+	e := &LedgerEntry{
+		Endpoint:   "bulk-send",
+		MessageID:  resp.MessageID,
+		Recipients: recipients,
+		Segments:   resp.NumberOfSMS,
+		Cost:       resp.Cost,
+		Gamme:      defaultGamme(bulksms.Gamme),
+		Tag:        bulksms.Tag,
+	}
+
+	responses := pairedResponses(resp, len(bulksms.SMSList))
+	if responses == nil {
+		e.Countries = splitCost(recipients, resp.NumberOfSMS, resp.Cost)
+		return e
+	}
+	byCountry := map[string]*LedgerCountry{}
+	for i, r := range responses {
+		sms := bulksms.SMSList[i]
+		if sms == nil {
+			continue
+		}
+		country := recipientCountry(sms.PhoneNumber, "FR")
+		c, ok := byCountry[country]
+		if !ok {
+			c = &LedgerCountry{Country: country, Cost: Money{Currency: resp.Cost.Currency}}
+			byCountry[country] = c
+			e.Countries = append(e.Countries, c)
+		}
+		c.Recipients++
+		if r != nil {
+			c.Segments += r.NumberOfSMS
+			c.Cost = MoneyFromMicros(c.Cost.Micros()+r.Cost.Micros(), c.Cost.Currency)
+		}
+	}
+	return e
+}
+
+// bill records e, or nothing if e is nil, in the ledger of c and ends the
+// reservation of estimate with its budget.
+func (c *Client) bill(estimate Money, e *LedgerEntry) {
+	if e != nil {
+		e.Time = time.Now()
+		e.SubAccount = c.subAccount
+	}
+	if c.budget != nil {
+		c.budget.release(estimate, e)
+		if c.budget.Ledger == c.ledger {
+			return
+		}
+	}
+	if e == nil || c.ledger == nil {
+		return
+	}
+	if err := c.ledger.Append(e); err != nil && c.ledger.OnError != nil {
+		c.ledger.OnError(fmt.Errorf("%s operation could not be recorded: %v", e.Endpoint, err))
+	}
+}
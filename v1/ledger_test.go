@@ -0,0 +1,163 @@
+package smspartner_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+func TestRecordCostsNilSMS(t *testing.T) {
+	ledger := smspartner.NewLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	cli, teardown := testingHTTPClient(t, &budgetHandler{t: t}, smspartner.RecordCosts(ledger), smspartner.SkipValidation())
+	defer teardown()
+
+	bulk := &smspartner.BulkSMS{SMSList: []*smspartner.SMSPayload{
+		{PhoneNumber: "0620123456", Message: "Hello"},
+		nil,
+		{PhoneNumber: "0621123456", Message: "Hello"},
+	}}
+	if _, err := cli.SendBulkSMS(bulk); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ledger.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || len(entries[0].Recipients) != 2 || entries[0].Cost.String() != "0.076 EUR" {
+		t.Errorf("unexpected entries: %s", jsonString(entries))
+	}
+}
+
+func TestRecordCosts(t *testing.T) {
+	ledger := smspartner.NewLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	cli, teardown := testingHTTPClient(t, &budgetHandler{t: t}, smspartner.RecordCosts(ledger))
+	defer teardown()
+
+	sms := &smspartner.SMS{PhoneNumbers: "0620123456,+32470123456", Message: "Hello", Tag: "promo"}
+	if _, err := cli.SendSMS(sms); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := cli.ForSubAccount(&smspartner.SubAccount{Token: "a1b2c3", APIKey: "sub-api-key-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bulk := &smspartner.BulkSMS{
+		Gamme: smspartner.LowCost,
+		Tag:   "promo",
+		SMSList: []*smspartner.SMSPayload{
+			{PhoneNumber: "0620123456", Message: "Hello"},
+			{PhoneNumber: "0621123456", Message: "Hello"},
+		},
+	}
+	if _, err := sub.SendBulkSMS(bulk); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ledger.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].SubAccount != "" || entries[1].SubAccount != "a1b2c3" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if e := entries[1]; e.MessageID != 2254444 || len(e.Recipients) != 2 || e.Segments != 2 || e.Cost.String() != "0.076 EUR" {
+		t.Errorf("unexpected bulk entry: %+v", e)
+	}
+
+	for _, tc := range []struct {
+		group smspartner.LedgerGroup
+		want  string
+	}{
+		{smspartner.GroupCountry, "BE:1:1:0.02 EUR FR:2:3:0.096 EUR"},
+		{smspartner.GroupSubAccount, ":1:2:0.04 EUR a1b2c3:1:2:0.076 EUR"},
+		{smspartner.GroupGamme, "lowcost:1:2:0.076 EUR premium:1:2:0.04 EUR"},
+		{smspartner.GroupTag, "promo:2:4:0.116 EUR"},
+	} {
+		totals, err := ledger.Totals(tc.group, time.Time{}, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, total := range totals {
+			got = append(got, strings.Join([]string{total.Key, strconv.Itoa(total.Operations), strconv.Itoa(total.Recipients), total.Cost.String()}, ":"))
+		}
+		if strings.Join(got, " ") != tc.want {
+			t.Errorf("%s: got: %s, want: %s", tc.group, strings.Join(got, " "), tc.want)
+		}
+	}
+
+	totals, err := ledger.Totals(smspartner.GroupDay, time.Now().Add(time.Hour), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(totals) != 0 {
+		t.Errorf("got %d totals in the future", len(totals))
+	}
+
+	var buf bytes.Buffer
+	if err := ledger.WriteCSV(&buf, time.Time{}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "time,endpoint,message_id") || !strings.Contains(lines[2], ",bulk-send,2254444,,a1b2c3,promo,lowcost,2,2,0.076,EUR") {
+		t.Errorf("unexpected CSV:\n%s", buf.String())
+	}
+}
+
+func TestLedgerSpent(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+	now := time.Now().In(paris)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, paris)
+	tomorrow := today.AddDate(0, 0, 1)
+
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	ledger := smspartner.NewLedger(path)
+	if err := ledger.Append(&smspartner.LedgerEntry{Time: today.AddDate(0, 0, -2), Endpoint: "send", Cost: smspartner.MustParseMoney("1", "EUR")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Append(&smspartner.LedgerEntry{Time: now, Endpoint: "send", Cost: smspartner.MustParseMoney("0.04", "EUR")}); err != nil {
+		t.Fatal(err)
+	}
+
+	spent, err := ledger.Spent(today, tomorrow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent.String() != "0.04 EUR" {
+		t.Errorf("got: %s, want: %s", spent, "0.04 EUR")
+	}
+
+	// the costs of the days are kept up to date without reading the file
+	if err := ledger.Append(&smspartner.LedgerEntry{Time: now, Endpoint: "send", Cost: smspartner.MustParseMoney("0.04", "EUR")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		from, to time.Time
+		want     string
+	}{
+		{today, tomorrow, "0.08 EUR"},
+		{today.AddDate(0, 0, -7), tomorrow, "1.08 EUR"},
+		{time.Time{}, time.Time{}, "1.08 EUR"},
+		{tomorrow, time.Time{}, "0"},
+	} {
+		spent, err := ledger.Spent(tc.from, tc.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if spent.String() != tc.want {
+			t.Errorf("[%s, %s): got: %s, want: %s", tc.from, tc.to, spent, tc.want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package smspartner
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hoflish/smspartner-go/v1/phonenumber"
+)
+
+// MaxLookupNumbers is the maximum number of phone numbers of a /lookup
+// request, see the lookup endpoint of the API documentation:
+// https://my.smspartner.fr/documentation-fr/api/v1
+const MaxLookupNumbers = 100
+
+// lookupConcurrency is the number of /lookup requests LookupNumbers makes
+// at once.
+const lookupConcurrency = 4
+
+// LookupError is the error of a number the API could not look up.
+type LookupError struct {
+	PhoneNumber string
+	Message     string
+}
+
+func (e *LookupError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("lookup of %s failed", e.PhoneNumber)
+	}
+	return fmt.Sprintf("lookup of %s failed: %s", e.PhoneNumber, e.Message)
+}
+
+// LookupResult is the result of the lookup of a number by LookupNumbers.
+// Err is a *LookupError if the API could not look it up, or the error of
+// the request.
+type LookupResult struct {
+	Lookup *Lookup
+	Err    error
+}
+
+// lookupKey returns the key of a number in a LookupCache: its E.164 form, or
+// the number itself if it is invalid.
+func lookupKey(number string) string {
+	if e164, err := phonenumber.Normalize(number, "FR"); err == nil {
+		return e164
+	}
+	return strings.TrimSpace(number)
+}
+
+// lookup makes a single /lookup request.
+func (c *Client) lookup(ctx context.Context, phoneNumbers []string) (*LookupResponse, error) {
+	payload := &NumberVerificationRequest{APIKey: c.apiKey, PhoneNumbers: strings.Join(phoneNumbers, ",")}
+	blob, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	fullURL := fmt.Sprintf("%s/lookup", c.basePath)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fullURL, bytes.NewReader(blob))
+	if err != nil {
+		return nil, err
+	}
+
+	blob, err = c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	lr := new(LookupResponse)
+	if err := json.Unmarshal(blob, lr); err != nil {
+		return nil, err
+	}
+	return lr, nil
+}
+
+// LookupNumbers looks up the format and type of phoneNumbers, in requests of
+// at most MaxLookupNumbers made concurrently, and returns the result of each
+// of them. With the CacheLookups option, the numbers already looked up are
+// not requested again.
+func (c *Client) LookupNumbers(ctx context.Context, phoneNumbers ...string) map[string]*LookupResult {
+	results := make(map[string]*LookupResult, len(phoneNumbers))
+	byKey := map[string]*LookupResult{}
+	var keys []string
+	for _, number := range phoneNumbers {
+		key := lookupKey(number)
+		if res, ok := byKey[key]; ok {
+			results[number] = res
+			continue
+		}
+		res := new(LookupResult)
+		if c.lookups != nil {
+			if l, ok := c.lookups.Get(key); ok {
+				res.Lookup, res.Err = l, lookupErr(key, l)
+				results[number], byKey[key] = res, res
+				continue
+			}
+		}
+		results[number], byKey[key] = res, res
+		keys = append(keys, key)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, lookupConcurrency)
+chunks:
+	for start := 0; start < len(keys); start += MaxLookupNumbers {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			// The chunks left are not requested.
+			for _, key := range keys[start:] {
+				byKey[key].Err = ctx.Err()
+			}
+			break chunks
+		}
+		end := start + MaxLookupNumbers
+		if end > len(keys) {
+			end = len(keys)
+		}
+		chunk := keys[start:end]
+
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			c.lookupChunk(ctx, chunk, byKey)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// lookupChunk looks up keys and fills their results, which are not shared
+// with the other chunks.
+func (c *Client) lookupChunk(ctx context.Context, keys []string, byKey map[string]*LookupResult) {
+	resp, err := c.lookup(ctx, keys)
+	if err != nil {
+		for _, key := range keys {
+			byKey[key].Err = err
+		}
+		return
+	}
+
+	// The lookups echo the number requested; when they do not, they are
+	// paired with the numbers by position if there is one for each.
+	lookups := map[string]*Lookup{}
+	for _, l := range resp.Lookup {
+		if l != nil {
+			lookups[strings.TrimSpace(l.Request)] = l
+		}
+	}
+	for i, key := range keys {
+		l, ok := lookups[key]
+		if !ok && len(resp.Lookup) == len(keys) {
+			l, ok = resp.Lookup[i], resp.Lookup[i] != nil
+		}
+		res := byKey[key]
+		if !ok {
+			res.Err = &LookupError{PhoneNumber: key, Message: "missing from the response"}
+			continue
+		}
+		res.Lookup, res.Err = l, lookupErr(key, l)
+		if c.lookups != nil {
+			c.lookups.Put(key, l)
+		}
+	}
+}
+
+func lookupErr(key string, l *Lookup) error {
+	if l.Success {
+		return nil
+	}
+	return &LookupError{PhoneNumber: key, Message: l.Message}
+}
+
+type lookupCacheEntry struct {
+	Key     string    `json:"key"`
+	Lookup  *Lookup   `json:"lookup"`
+	Expires time.Time `json:"expires"`
+}
+
+// LookupCache is a least recently used cache of the lookups of numbers,
+// which expire after a time to live. It can be saved to and loaded from a
+// file. See the CacheLookups option.
+type LookupCache struct {
+	Path string // file of Load and Save
+
+	size    int
+	ttl     time.Duration
+	mu      sync.Mutex
+	order   *list.List // of *lookupCacheEntry, the most recently used first
+	entries map[string]*list.Element
+}
+
+// NewLookupCache returns a cache of at most size lookups, kept for ttl.
+func NewLookupCache(size int, ttl time.Duration) *LookupCache {
+	return &LookupCache{size: size, ttl: ttl, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// NewFileLookupCache returns a cache kept in the file path, loading the
+// lookups it holds, if any. See Save.
+func NewFileLookupCache(path string, size int, ttl time.Duration) (*LookupCache, error) {
+	cache := NewLookupCache(size, ttl)
+	cache.Path = path
+	if err := cache.Load(); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// Get returns the lookup of the E.164 number key, unless it has expired.
+func (lc *LookupCache) Get(key string) (*Lookup, bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	el, ok := lc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*lookupCacheEntry)
+	if time.Now().After(e.Expires) {
+		lc.order.Remove(el)
+		delete(lc.entries, key)
+		return nil, false
+	}
+	lc.order.MoveToFront(el)
+	return e.Lookup, true
+}
+
+// Put adds the lookup of the E.164 number key, evicting the least recently
+// used lookup if the cache is full.
+func (lc *LookupCache) Put(key string, l *Lookup) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.put(&lookupCacheEntry{Key: key, Lookup: l, Expires: time.Now().Add(lc.ttl)})
+}
+
+func (lc *LookupCache) put(e *lookupCacheEntry) {
+	if el, ok := lc.entries[e.Key]; ok {
+		el.Value = e
+		lc.order.MoveToFront(el)
+		return
+	}
+	lc.entries[e.Key] = lc.order.PushFront(e)
+	for lc.size > 0 && lc.order.Len() > lc.size {
+		last := lc.order.Back()
+		lc.order.Remove(last)
+		delete(lc.entries, last.Value.(*lookupCacheEntry).Key)
+	}
+}
+
+// Len returns the number of lookups in the cache, including the expired ones
+// not evicted yet.
+func (lc *LookupCache) Len() int {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.order.Len()
+}
+
+// Load adds the lookups saved in Path that have not expired. A missing file
+// is an empty cache.
+func (lc *LookupCache) Load() error {
+	blob, err := os.ReadFile(lc.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved []*lookupCacheEntry
+	if err := json.Unmarshal(blob, &saved); err != nil {
+		return fmt.Errorf("error reading lookup cache %s: %v", lc.Path, err)
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	now := time.Now()
+	// Saved the most recently used first.
+	for i := len(saved) - 1; i >= 0; i-- {
+		if e := saved[i]; e != nil && e.Lookup != nil && now.Before(e.Expires) {
+			lc.put(e)
+		}
+	}
+	return nil
+}
+
+// Save writes the lookups that have not expired to Path.
+func (lc *LookupCache) Save() error {
+	lc.mu.Lock()
+	now := time.Now()
+	saved := make([]*lookupCacheEntry, 0, lc.order.Len())
+	for el := lc.order.Front(); el != nil; el = el.Next() {
+		if e := el.Value.(*lookupCacheEntry); now.Before(e.Expires) {
+			saved = append(saved, e)
+		}
+	}
+	lc.mu.Unlock()
+
+	blob, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(lc.Path, blob)
+}
@@ -0,0 +1,170 @@
+package smspartner_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+// lookupHandler answers a successful lookup for each number requested,
+// except the numbers ending with 99.
+type lookupHandler struct {
+	mu       sync.Mutex
+	requests int
+	t        *testing.T
+}
+
+func (h *lookupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req smspartner.NumberVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.t.Error(err)
+		return
+	}
+	numbers := strings.Split(req.PhoneNumbers, ",")
+	if len(numbers) > smspartner.MaxLookupNumbers {
+		h.t.Errorf("got %d numbers in a request", len(numbers))
+	}
+	h.mu.Lock()
+	h.requests++
+	h.mu.Unlock()
+
+	resp := &smspartner.LookupResponse{Success: true, Code: 200}
+	for _, number := range numbers {
+		l := &smspartner.Lookup{Request: number, Success: true, PhoneNumber: number, Type: "Mobile"}
+		if strings.HasSuffix(number, "99") {
+			l = &smspartner.Lookup{Request: number, Message: "Le numéro de téléphone est vide ou n'est pas valide."}
+		}
+		resp.Lookup = append(resp.Lookup, l)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestLookupNumbers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lookups.json")
+	cache, err := smspartner.NewFileLookupCache(path, 1000, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &lookupHandler{t: t}
+	cli, teardown := testingHTTPClient(t, h, smspartner.CacheLookups(cache))
+	defer teardown()
+
+	var numbers []string
+	for i := 0; i < 250; i++ {
+		numbers = append(numbers, fmt.Sprintf("0620%06d", i))
+	}
+	// The same number, formatted differently.
+	numbers = append(numbers, "+33 6 20 00 00 00")
+
+	results := cli.LookupNumbers(context.Background(), numbers...)
+	if h.requests != 3 {
+		t.Errorf("got %d requests, want: 3", h.requests)
+	}
+	if len(results) != len(numbers) {
+		t.Fatalf("got %d results, want: %d", len(results), len(numbers))
+	}
+	if res := results["+33 6 20 00 00 00"]; res.Err != nil || res.Lookup.PhoneNumber != "+33620000000" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	var lookupErr *smspartner.LookupError
+	if res := results["0620000099"]; !errors.As(res.Err, &lookupErr) {
+		t.Errorf("got error: %v, want: a *LookupError", res.Err)
+	}
+
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := smspartner.NewFileLookupCache(path, 1000, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != 250 {
+		t.Errorf("got %d lookups loaded, want: 250", loaded.Len())
+	}
+
+	cli, teardown = testingHTTPClient(t, h, smspartner.CacheLookups(loaded))
+	defer teardown()
+	cli.LookupNumbers(context.Background(), numbers[:100]...)
+	if h.requests != 3 {
+		t.Errorf("got %d requests, want: 3", h.requests)
+	}
+}
+
+func TestLookupNumbersCancel(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	started := make(chan struct{}, 10)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		started <- struct{}{}
+		// The body is read for the server to notice the client going away.
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	})
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	var numbers []string
+	for i := 0; i < 6*smspartner.MaxLookupNumbers; i++ {
+		numbers = append(numbers, fmt.Sprintf("0620%06d", i))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	done := make(chan map[string]*smspartner.LookupResult)
+	go func() { done <- cli.LookupNumbers(ctx, numbers...) }()
+	select {
+	case results := <-done:
+		for _, number := range numbers {
+			if res := results[number]; res == nil || res.Err == nil {
+				t.Fatalf("got result: %+v, want an error", res)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("LookupNumbers did not return once canceled")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// No chunk is requested once the context is done.
+	if requests > 4 {
+		t.Errorf("got %d requests, want at most 4", requests)
+	}
+}
+
+func TestLookupCache(t *testing.T) {
+	cache := smspartner.NewLookupCache(2, time.Hour)
+	cache.Put("+33620123401", &smspartner.Lookup{Success: true})
+	cache.Put("+33620123402", &smspartner.Lookup{Success: true})
+	cache.Get("+33620123401")
+	cache.Put("+33620123403", &smspartner.Lookup{Success: true})
+
+	if _, ok := cache.Get("+33620123402"); ok {
+		t.Error("the least recently used lookup was not evicted")
+	}
+	if _, ok := cache.Get("+33620123401"); !ok {
+		t.Error("a recently used lookup was evicted")
+	}
+
+	cache = smspartner.NewLookupCache(2, time.Millisecond)
+	cache.Put("+33620123401", &smspartner.Lookup{Success: true})
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.Get("+33620123401"); ok {
+		t.Error("an expired lookup was returned")
+	}
+}
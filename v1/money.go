@@ -0,0 +1,293 @@
+package smspartner
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// moneyDecimals is the number of decimals kept by Money.
+const moneyDecimals = 6
+
+const moneyScale = 1000000 // 10^moneyDecimals
+
+var ErrCurrencyMismatch = errors.New("Amounts of different currencies can not be combined")
+
+// Money is an amount of money with a fixed number of decimals, so that sums
+// of costs do not drift, and its ISO 4217 currency code (e.g. "EUR").
+// An empty currency matches any other, e.g. for amounts set in a
+// configuration.
+//
+// It is encoded as a JSON object with its amount and currency, e.g.
+// {"amount":"0.04","currency":"EUR"}, or as a JSON number if it has no
+// currency. It is decoded from these or from a JSON string, e.g. "0.900":
+// the responses of the API set the currency from their currency field.
+type Money struct {
+	Currency string
+	micros   int64
+}
+
+// MoneyFromMicros returns the amount of micros millionths of currency.
+func MoneyFromMicros(micros int64, currency string) Money {
+	return Money{Currency: currency, micros: micros}
+}
+
+// ParseMoney parses a decimal amount, e.g. "12", "-0.038" or "0,90".
+// Decimals beyond the sixth are rounded.
+func ParseMoney(amount, currency string) (Money, error) {
+	micros, err := parseMicros(amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Currency: currency, micros: micros}, nil
+}
+
+// MustParseMoney is like ParseMoney but panics if amount is not valid.
+func MustParseMoney(amount, currency string) Money {
+	m, err := ParseMoney(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func parseMicros(amount string) (int64, error) {
+	s := strings.Replace(strings.TrimSpace(amount), ",", ".", 1)
+	invalid := fmt.Errorf("invalid amount %q", amount)
+
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) || math.Abs(f) >= math.MaxInt64/moneyScale {
+			return 0, invalid
+		}
+		return int64(math.Round(f * moneyScale)), nil
+	}
+
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !digitsOnly(whole) || !digitsOnly(frac) || len(whole) > 12 {
+		return 0, invalid
+	}
+
+	var micros int64
+	if whole != "" {
+		micros, _ = strconv.ParseInt(whole, 10, 64)
+		micros *= moneyScale
+	}
+	for i := 0; i < moneyDecimals; i++ {
+		d := int64(0)
+		if i < len(frac) {
+			d = int64(frac[i] - '0')
+		}
+		micros += d * int64(math.Pow10(moneyDecimals-1-i))
+	}
+	if len(frac) > moneyDecimals && frac[moneyDecimals] >= '5' {
+		micros++
+	}
+	if neg {
+		micros = -micros
+	}
+	return micros, nil
+}
+
+func digitsOnly(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Micros returns the amount in millionths of the currency.
+func (m Money) Micros() int64 {
+	return m.micros
+}
+
+// Float64 returns the amount as a float, e.g. for display or statistics.
+func (m Money) Float64() float64 {
+	return float64(m.micros) / moneyScale
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.micros == 0
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of the amount.
+func (m Money) Sign() int {
+	switch {
+	case m.micros < 0:
+		return -1
+	case m.micros > 0:
+		return 1
+	}
+	return 0
+}
+
+func (m Money) currency(o Money) (string, error) {
+	switch {
+	case m.Currency == "":
+		return o.Currency, nil
+	case o.Currency == "" || o.Currency == m.Currency:
+		return m.Currency, nil
+	}
+	return "", ErrCurrencyMismatch
+}
+
+// Add returns m+o.
+func (m Money) Add(o Money) (Money, error) {
+	cur, err := m.currency(o)
+	if err != nil {
+		return m, err
+	}
+	return Money{Currency: cur, micros: m.micros + o.micros}, nil
+}
+
+// Sub returns m-o.
+func (m Money) Sub(o Money) (Money, error) {
+	return m.Add(o.Neg())
+}
+
+// Neg returns -m.
+func (m Money) Neg() Money {
+	return Money{Currency: m.Currency, micros: -m.micros}
+}
+
+// Mul returns m*n.
+func (m Money) Mul(n int64) Money {
+	return Money{Currency: m.Currency, micros: m.micros * n}
+}
+
+// Cmp compares the amounts of m and o and returns -1, 0 or +1.
+func (m Money) Cmp(o Money) (int, error) {
+	if _, err := m.currency(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.micros < o.micros:
+		return -1, nil
+	case m.micros > o.micros:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Amount returns the amount without trailing zeros, e.g. "0.038" or "12".
+func (m Money) Amount() string {
+	return m.format(-1)
+}
+
+// Format returns the amount with the given number of decimals, rounded,
+// e.g. Format(2) returns "0.04" for 0.038.
+func (m Money) Format(decimals int) string {
+	if decimals > moneyDecimals {
+		decimals = moneyDecimals
+	}
+	if decimals < 0 {
+		decimals = 0
+	}
+	return m.format(decimals)
+}
+
+func (m Money) format(decimals int) string {
+	micros := m.micros
+	sign := ""
+	if micros < 0 {
+		sign, micros = "-", -micros
+	}
+	if decimals >= 0 {
+		unit := int64(math.Pow10(moneyDecimals - decimals))
+		micros = (micros + unit/2) / unit * unit
+	}
+
+	frac := fmt.Sprintf("%06d", micros%moneyScale)
+	if decimals < 0 {
+		frac = strings.TrimRight(frac, "0")
+	} else {
+		frac = frac[:decimals]
+	}
+	s := sign + strconv.FormatInt(micros/moneyScale, 10)
+	if frac != "" {
+		s += "." + frac
+	}
+	return s
+}
+
+// String returns the amount and its currency, e.g. "0.038 EUR".
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Amount()
+	}
+	return m.Amount() + " " + m.Currency
+}
+
+// jsonMoney is the JSON object of a Money with a currency.
+type jsonMoney struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency,omitempty"`
+}
+
+// MarshalJSON encodes the amount and currency as a JSON object, or the
+// amount as a JSON number if the currency is empty.
+func (m Money) MarshalJSON() ([]byte, error) {
+	if m.Currency == "" {
+		return []byte(m.Amount()), nil
+	}
+	amount, err := json.Marshal(m.Amount())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&jsonMoney{Amount: amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON decodes an amount from a JSON number or string, or an
+// amount and currency from a JSON object. An empty string or null is zero.
+// The currency is left unchanged unless the object has one.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.HasPrefix(b, []byte("{")) {
+		var v jsonMoney
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(v.Amount), []byte("{")) {
+			return fmt.Errorf("invalid amount %s", v.Amount)
+		}
+		if len(v.Amount) == 0 {
+			v.Amount = []byte("null")
+		}
+		if err := m.UnmarshalJSON(v.Amount); err != nil {
+			return err
+		}
+		if v.Currency != "" {
+			m.Currency = v.Currency
+		}
+		return nil
+	}
+	if bytes.Equal(b, []byte("null")) {
+		m.micros = 0
+		return nil
+	}
+	s := string(b)
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		if strings.TrimSpace(s) == "" {
+			m.micros = 0
+			return nil
+		}
+	}
+	micros, err := parseMicros(s)
+	if err != nil {
+		return err
+	}
+	m.micros = micros
+	return nil
+}
@@ -0,0 +1,130 @@
+package smspartner_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in     string
+		micros int64
+		amount string
+	}{
+		{"0.900", 900000, "0.9"},
+		{"12", 12000000, "12"},
+		{"-0.038", -38000, "-0.038"},
+		{"0,90", 900000, "0.9"},
+		{" .5 ", 500000, "0.5"},
+		{"0.00000049", 0, "0"},
+		{"0.0000005", 1, "0.000001"},
+		{"1e-3", 1000, "0.001"},
+	}
+	for _, tt := range tests {
+		m, err := smspartner.ParseMoney(tt.in, "EUR")
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if m.Micros() != tt.micros || m.Amount() != tt.amount {
+			t.Errorf("%q: got: %d (%s), want: %d (%s)", tt.in, m.Micros(), m.Amount(), tt.micros, tt.amount)
+		}
+	}
+
+	for _, in := range []string{"", "-", ".", "ten", "1.2.3", "NaN", "1 000"} {
+		if _, err := smspartner.ParseMoney(in, "EUR"); err == nil {
+			t.Errorf("%q: expected a non-nil error", in)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	cost := smspartner.MustParseMoney("0.038", "EUR")
+
+	var total smspartner.Money
+	for i := 0; i < 10000; i++ {
+		var err error
+		if total, err = total.Add(cost); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := smspartner.MustParseMoney("380", "EUR"); total != want {
+		t.Errorf("got: %s, want: %s", total, want)
+	}
+	if got := cost.Mul(3).Format(2); got != "0.11" {
+		t.Errorf("got: %s, want: %s", got, "0.11")
+	}
+	if got := cost.Neg().Format(2); got != "-0.04" {
+		t.Errorf("got: %s, want: %s", got, "-0.04")
+	}
+
+	diff, err := cost.Sub(smspartner.MustParseMoney("0.04", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.String() != "-0.002 EUR" || diff.Sign() != -1 {
+		t.Errorf("got: %s", diff)
+	}
+
+	usd := smspartner.MustParseMoney("1", "USD")
+	if _, err := cost.Add(usd); err != smspartner.ErrCurrencyMismatch {
+		t.Errorf("got: %v, want: %v", err, smspartner.ErrCurrencyMismatch)
+	}
+	if _, err := cost.Cmp(usd); err != smspartner.ErrCurrencyMismatch {
+		t.Errorf("got: %v, want: %v", err, smspartner.ErrCurrencyMismatch)
+	}
+	if n, err := cost.Cmp(smspartner.MustParseMoney("0.04", "EUR")); err != nil || n != -1 {
+		t.Errorf("got: %d, %v, want: -1", n, err)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var v struct {
+		Balance smspartner.Money   `json:"balance"`
+		Costs   []smspartner.Money `json:"costs"`
+	}
+	if err := json.Unmarshal([]byte(`{"balance": "0.900", "costs": [0.04, "", null, "1.5"]}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"balance":0.9,"costs":[0.04,0,0,1.5]}`; string(b) != want {
+		t.Errorf("got: %s, want: %s", b, want)
+	}
+
+	if err := json.Unmarshal([]byte(`{"balance": "ten"}`), &v); err == nil {
+		t.Error("expected a non-nil error")
+	}
+
+	// the currency is kept when it is set
+	v.Balance, v.Costs = smspartner.MustParseMoney("0.040", "EUR"), nil
+	if b, err = json.Marshal(v); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"balance":{"amount":"0.04","currency":"EUR"},"costs":null}`; string(b) != want {
+		t.Errorf("got: %s, want: %s", b, want)
+	}
+	v.Balance = smspartner.Money{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if got := v.Balance.String(); got != "0.04 EUR" {
+		t.Errorf("got: %s, want: %s", got, "0.04 EUR")
+	}
+
+	var credits smspartner.CreditsResponse
+	blob, err := fixture("credits.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(blob, &credits); err != nil {
+		t.Fatal(err)
+	}
+	if got := credits.Credits.Balance.String(); got != "0.9 EUR" {
+		t.Errorf("got: %s, want: %s", got, "0.9 EUR")
+	}
+}
@@ -0,0 +1,222 @@
+package smspartner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultMonitorWindow is the period over which the consumption rate is
+// measured when CreditMonitor.Window is zero.
+const defaultMonitorWindow = 6 * time.Hour
+
+// CreditMetric is a counter of Credits watched by a CreditMonitor.
+type CreditMetric string
+
+// List of values that CreditMetric can take.
+const (
+	MetricSMS        CreditMetric = "creditSms"
+	MetricSMSLowCost CreditMetric = "creditSmsLowCost"
+	MetricHLR        CreditMetric = "creditHlr"
+	MetricToSend     CreditMetric = "toSend"
+)
+
+func (m CreditMetric) value(cr *Credits) int {
+	switch m {
+	case MetricSMS:
+		return cr.CreditSMS
+	case MetricSMSLowCost:
+		return cr.CreditSmsLowCost
+	case MetricHLR:
+		return cr.CreditHlr
+	case MetricToSend:
+		return cr.ToSend
+	}
+	return 0
+}
+
+// CreditThreshold raises an alert when Metric falls below Below, or rises
+// above Above if Above is not zero, e.g. for MetricToSend. The alert is
+// cleared once the value is back Hysteresis past the threshold, so that
+// values around the threshold do not raise an alert on every poll.
+type CreditThreshold struct {
+	Metric     CreditMetric
+	Below      int
+	Above      int
+	Hysteresis int
+}
+
+// crossed reports whether v raises the alert of th, and whether it clears it.
+func (th *CreditThreshold) crossed(v int) (raise, clear bool) {
+	if th.Above != 0 {
+		return v > th.Above, v <= th.Above-th.Hysteresis
+	}
+	return v < th.Below, v >= th.Below+th.Hysteresis
+}
+
+// CreditAlert is passed to the callbacks of a CreditMonitor when a threshold
+// is crossed, and again with Cleared set when the value has recovered.
+type CreditAlert struct {
+	Threshold *CreditThreshold
+	Value     int
+	Cleared   bool
+	At        time.Time
+
+	// HoursLeft is the projected time until the metric is exhausted at the
+	// recent consumption rate, if known.
+	HoursLeft float64
+	Projected bool
+}
+
+func (a *CreditAlert) String() string {
+	state := "alert"
+	if a.Cleared {
+		state = "cleared"
+	}
+	msg := fmt.Sprintf("%s %s: %d", a.Threshold.Metric, state, a.Value)
+	if a.Projected {
+		msg += fmt.Sprintf(" (%.1f hours left)", a.HoursLeft)
+	}
+	return msg
+}
+
+type creditSample struct {
+	at      time.Time
+	credits Credits
+}
+
+// CreditMonitor polls CheckCredits and calls the callbacks registered with
+// OnAlert when the thresholds are crossed.
+type CreditMonitor struct {
+	Thresholds []*CreditThreshold
+	Window     time.Duration // defaults to 6 hours
+
+	// OnError is called with the errors of the polls made by Run.
+	OnError func(error)
+
+	client    *Client
+	mu        sync.Mutex
+	callbacks []func(*CreditAlert)
+	samples   []creditSample
+	alerted   map[*CreditThreshold]bool
+}
+
+// NewCreditMonitor returns a monitor of the credits of c.
+func (c *Client) NewCreditMonitor(thresholds ...*CreditThreshold) *CreditMonitor {
+	return &CreditMonitor{Thresholds: thresholds, client: c, alerted: map[*CreditThreshold]bool{}}
+}
+
+// OnAlert registers f to be called, from the polling goroutine, on alerts.
+func (m *CreditMonitor) OnAlert(f func(*CreditAlert)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.callbacks = append(m.callbacks, f)
+}
+
+func (m *CreditMonitor) window() time.Duration {
+	if m.Window <= 0 {
+		return defaultMonitorWindow
+	}
+	return m.Window
+}
+
+// Run polls the credits every interval until ctx is done.
+func (m *CreditMonitor) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := m.Poll(ctx); err != nil && m.OnError != nil && ctx.Err() == nil {
+			m.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll checks the credits once and returns the alerts raised or cleared.
+func (m *CreditMonitor) Poll(ctx context.Context) ([]*CreditAlert, error) {
+	resp, err := m.client.checkCredits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Credits == nil {
+		return nil, fmt.Errorf("unexpected response: no credits")
+	}
+	return m.Record(time.Now(), resp.Credits), nil
+}
+
+// Record adds credits, read at the given time, to the samples of the monitor
+// and returns the alerts raised or cleared, after calling the callbacks.
+func (m *CreditMonitor) Record(at time.Time, credits *Credits) []*CreditAlert {
+	m.mu.Lock()
+	m.samples = append(m.samples, creditSample{at: at, credits: *credits})
+	start := at.Add(-m.window())
+	for len(m.samples) > 2 && m.samples[1].at.Before(start) {
+		m.samples = m.samples[1:]
+	}
+
+	var alerts []*CreditAlert
+	for _, th := range m.Thresholds {
+		v := th.Metric.value(credits)
+		raise, clear := th.crossed(v)
+		alert := &CreditAlert{Threshold: th, Value: v, At: at}
+		switch {
+		case !m.alerted[th] && raise:
+			m.alerted[th] = true
+		case m.alerted[th] && clear:
+			m.alerted[th], alert.Cleared = false, true
+		default:
+			continue
+		}
+		alert.HoursLeft, alert.Projected = m.hoursLeft(th.Metric)
+		alerts = append(alerts, alert)
+	}
+	callbacks := m.callbacks
+	m.mu.Unlock()
+
+	for _, a := range alerts {
+		for _, f := range callbacks {
+			f(a)
+		}
+	}
+	return alerts
+}
+
+// HoursLeft returns the projected time until metric is exhausted, based on
+// its consumption over the window of the monitor. It reports false when
+// there is not enough data or nothing has been consumed, and for
+// MetricToSend, which is not a balance.
+func (m *CreditMonitor) HoursLeft(metric CreditMetric) (float64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hoursLeft(metric)
+}
+
+func (m *CreditMonitor) hoursLeft(metric CreditMetric) (float64, bool) {
+	if len(m.samples) < 2 || metric == MetricToSend {
+		return 0, false
+	}
+
+	// Only the decreases count, so that buying credits does not hide the
+	// consumption.
+	consumed := 0
+	for i := 1; i < len(m.samples); i++ {
+		if d := metric.value(&m.samples[i-1].credits) - metric.value(&m.samples[i].credits); d > 0 {
+			consumed += d
+		}
+	}
+	first, last := m.samples[0], m.samples[len(m.samples)-1]
+	hours := last.at.Sub(first.at).Hours()
+	if consumed == 0 || hours <= 0 {
+		return 0, false
+	}
+	left := metric.value(&last.credits)
+	if left < 0 {
+		left = 0
+	}
+	return float64(left) / (float64(consumed) / hours), true
+}
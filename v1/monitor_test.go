@@ -0,0 +1,78 @@
+package smspartner_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+func TestCreditMonitor(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s", r.URL.Path)
+	})
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	low := &smspartner.CreditThreshold{Metric: smspartner.MetricSMS, Below: 500, Hysteresis: 100}
+	queued := &smspartner.CreditThreshold{Metric: smspartner.MetricToSend, Above: 1000}
+	m := cli.NewCreditMonitor(low, queued)
+
+	var got []string
+	m.OnAlert(func(a *smspartner.CreditAlert) { got = append(got, a.String()) })
+
+	start := time.Date(2018, 8, 18, 8, 0, 0, 0, time.UTC)
+	for i, credits := range []smspartner.Credits{
+		{CreditSMS: 1000},
+		{CreditSMS: 700},
+		{CreditSMS: 400},                // alert, 1.3 hours left at 300 per hour
+		{CreditSMS: 550},                // still below the hysteresis
+		{CreditSMS: 450, ToSend: 1200},  // no new alert
+		{CreditSMS: 2450, ToSend: 1000}, // cleared
+	} {
+		c := credits
+		m.Record(start.Add(time.Duration(i)*time.Hour), &c)
+	}
+
+	want := []string{
+		"creditSms alert: 400 (1.3 hours left)",
+		"toSend alert: 1200",
+		"creditSms cleared: 2450 (17.5 hours left)",
+		"toSend cleared: 1000",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	if _, ok := m.HoursLeft(smspartner.MetricHLR); ok {
+		t.Error("expected no projection without consumption")
+	}
+}
+
+func TestCreditMonitorPoll(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		b, err := fixture("credits.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	m := cli.NewCreditMonitor(&smspartner.CreditThreshold{Metric: smspartner.MetricSMS, Below: 50})
+	alerts, err := m.Poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Value != 20 || alerts[0].Projected {
+		t.Errorf("unexpected alerts: %v", alerts)
+	}
+	if alerts, _ := m.Poll(context.Background()); len(alerts) != 0 {
+		t.Errorf("unexpected alerts: %v", alerts)
+	}
+}
@@ -0,0 +1,303 @@
+// Package phonenumber parses and formats phone numbers offline, for France
+// (including overseas departments) and the main European and Maghreb
+// countries, producing the same formats as the /lookup endpoint.
+package phonenumber
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Type is the kind of line a phone number belongs to.
+type Type int
+
+// List of values that Type can take.
+const (
+	Unknown Type = iota
+	FixedLine
+	Mobile
+	TollFree
+	SharedCost
+	Premium
+	VoIP
+)
+
+var typeNames = map[string]Type{
+	"fixedLine":  FixedLine,
+	"mobile":     Mobile,
+	"tollFree":   TollFree,
+	"sharedCost": SharedCost,
+	"premium":    Premium,
+	"voip":       VoIP,
+}
+
+// String returns the type as written by the /lookup endpoint, e.g. "Mobile".
+func (t Type) String() string {
+	switch t {
+	case FixedLine:
+		return "Fixed line"
+	case Mobile:
+		return "Mobile"
+	case TollFree:
+		return "Toll free"
+	case SharedCost:
+		return "Shared cost"
+	case Premium:
+		return "Premium rate"
+	case VoIP:
+		return "VoIP"
+	}
+	return "Unknown"
+}
+
+var (
+	ErrInvalidNumber = errors.New("phonenumber: invalid phone number")
+	ErrUnknownRegion = errors.New("phonenumber: unsupported region or country code")
+)
+
+//go:embed metadata.json
+var metadataJSON []byte
+
+type numberFormat struct {
+	Length int   `json:"length"`
+	Groups []int `json:"groups"`
+}
+
+type regionMetadata struct {
+	Region    string              `json:"region"`
+	Code      int                 `json:"code"`
+	Trunk     string              `json:"trunk"`
+	MinLength int                 `json:"minLength"`
+	MaxLength int                 `json:"maxLength"`
+	Parent    string              `json:"parent"`
+	Formats   []numberFormat      `json:"formats"`
+	Separator string              `json:"separator"`
+	Types     map[string][]string `json:"types"`
+}
+
+var (
+	regions  []*regionMetadata
+	byRegion = map[string]*regionMetadata{}
+	byCode   = map[int][]*regionMetadata{}
+)
+
+func init() {
+	if err := json.Unmarshal(metadataJSON, &regions); err != nil {
+		panic("phonenumber: invalid metadata: " + err.Error())
+	}
+	for _, md := range regions {
+		byRegion[md.Region] = md
+		byCode[md.Code] = append(byCode[md.Code], md)
+	}
+}
+
+// Regions returns the ISO 3166 codes of the supported regions.
+func Regions() []string {
+	codes := make([]string, len(regions))
+	for i, md := range regions {
+		codes[i] = md.Region
+	}
+	return codes
+}
+
+// CountryCode returns the calling code of region, or 0 if it is not supported.
+func CountryCode(region string) int {
+	if md, ok := byRegion[strings.ToUpper(region)]; ok {
+		return md.Code
+	}
+	return 0
+}
+
+// PhoneNumber is a parsed phone number.
+type PhoneNumber struct {
+	Raw            string
+	Region         string // ISO 3166 code, e.g. "FR" or "RE"
+	CountryCode    int    // e.g. 33
+	NationalNumber string // significant number, without trunk prefix
+	Type           Type
+}
+
+// Format holds the formats of a phone number returned by the /lookup endpoint.
+type Format struct {
+	E164          string `json:"e164,omitempty"`
+	International string `json:"international,omitempty"`
+	National      string `json:"national,omitempty"`
+	RFC3966       string `json:"rfc3966,omitempty"`
+}
+
+// Parse parses raw, written either in international format ("+33 6…",
+// "0033 6…") or in the national format of defaultRegion ("06…").
+func Parse(raw, defaultRegion string) (*PhoneNumber, error) {
+	digits, international, err := clean(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var md *regionMetadata
+	var nsn string
+	if international {
+		md, nsn, err = splitCountryCode(digits)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", err, raw)
+		}
+		// "+33 06…": drop the trunk prefix written by mistake.
+		if md.Trunk != "" && strings.HasPrefix(nsn, md.Trunk) && len(nsn)-len(md.Trunk) >= md.MinLength && len(nsn) > md.MaxLength {
+			nsn = nsn[len(md.Trunk):]
+		}
+	} else {
+		var ok bool
+		md, ok = byRegion[strings.ToUpper(defaultRegion)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownRegion, defaultRegion)
+		}
+		if md.Trunk != "" {
+			if !strings.HasPrefix(digits, md.Trunk) {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidNumber, raw)
+			}
+			digits = digits[len(md.Trunk):]
+		}
+		nsn = digits
+	}
+
+	md = resolveRegion(md, nsn, !international)
+	if len(nsn) < md.MinLength || len(nsn) > md.MaxLength {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidNumber, raw)
+	}
+
+	return &PhoneNumber{
+		Raw:            raw,
+		Region:         md.Region,
+		CountryCode:    md.Code,
+		NationalNumber: nsn,
+		Type:           md.classify(nsn),
+	}, nil
+}
+
+// Normalize returns raw in E.164 format, see Parse.
+func Normalize(raw, defaultRegion string) (string, error) {
+	p, err := Parse(raw, defaultRegion)
+	if err != nil {
+		return "", err
+	}
+	return p.E164(), nil
+}
+
+// clean strips the formatting characters of raw and reports whether it is
+// written in international format.
+func clean(raw string) (digits string, international bool, err error) {
+	s := strings.TrimSpace(raw)
+	s = strings.TrimPrefix(s, "tel:")
+	s = strings.Replace(s, "(0)", "", 1)
+
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			international = true
+		case strings.ContainsRune(" .-()/\u00a0", r):
+		default:
+			return "", false, fmt.Errorf("%w: %q", ErrInvalidNumber, raw)
+		}
+	}
+
+	digits = b.String()
+	if !international && strings.HasPrefix(digits, "00") {
+		digits, international = digits[2:], true
+	}
+	if digits == "" {
+		return "", false, fmt.Errorf("%w: %q", ErrInvalidNumber, raw)
+	}
+	return digits, international, nil
+}
+
+// splitCountryCode splits digits into a country code and a national number.
+func splitCountryCode(digits string) (*regionMetadata, string, error) {
+	for n := 1; n <= 3 && n < len(digits); n++ {
+		code, _ := strconv.Atoi(digits[:n])
+		if mds, ok := byCode[code]; ok {
+			return mds[0], digits[n:], nil
+		}
+	}
+	return nil, "", ErrUnknownRegion
+}
+
+// resolveRegion picks, among the regions sharing the numbering plan of md,
+// the one nsn belongs to: +262 is shared by Réunion and Mayotte, and the
+// overseas departments are dialed as French national numbers. Only a
+// national number is read in the plan of the parent of md: "+590 6…" is not
+// the French "06…".
+func resolveRegion(md *regionMetadata, nsn string, national bool) *regionMetadata {
+	for _, other := range regions {
+		if other != md && (other.Code == md.Code || other.Parent == md.Region) && other.classify(nsn) != Unknown {
+			return other
+		}
+	}
+	if national && md.classify(nsn) == Unknown && md.Parent != "" {
+		if parent := byRegion[md.Parent]; parent.classify(nsn) != Unknown {
+			return parent
+		}
+	}
+	return md
+}
+
+// classify returns the type whose prefix is the longest match for nsn.
+func (md *regionMetadata) classify(nsn string) Type {
+	best, bestLen := Unknown, 0
+	for name, prefixes := range md.Types {
+		for _, p := range prefixes {
+			if len(p) > bestLen && strings.HasPrefix(nsn, p) {
+				best, bestLen = typeNames[name], len(p)
+			}
+		}
+	}
+	return best
+}
+
+// E164 returns the number in E.164 format, e.g. "+33612345678".
+func (p *PhoneNumber) E164() string {
+	return "+" + strconv.Itoa(p.CountryCode) + p.NationalNumber
+}
+
+// String returns the number in E.164 format.
+func (p *PhoneNumber) String() string {
+	return p.E164()
+}
+
+// Format returns the number in the formats returned by the /lookup endpoint.
+func (p *PhoneNumber) Format() Format {
+	md := byRegion[p.Region]
+	cc := strconv.Itoa(p.CountryCode)
+	return Format{
+		E164:          p.E164(),
+		International: "+" + cc + " " + md.group(p.NationalNumber, md.Separator),
+		National:      md.Trunk + md.group(p.NationalNumber, md.Separator),
+		RFC3966:       "tel:+" + cc + "-" + md.group(p.NationalNumber, "-"),
+	}
+}
+
+// group splits nsn with the grouping of its length, the last group taking
+// the remaining digits.
+func (md *regionMetadata) group(nsn, sep string) string {
+	for _, f := range md.Formats {
+		if f.Length != len(nsn) {
+			continue
+		}
+		var parts []string
+		rest := nsn
+		for i, n := range f.Groups {
+			if i == len(f.Groups)-1 || n >= len(rest) {
+				break
+			}
+			parts = append(parts, rest[:n])
+			rest = rest[n:]
+		}
+		return strings.Join(append(parts, rest), sep)
+	}
+	return nsn
+}
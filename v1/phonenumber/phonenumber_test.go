@@ -0,0 +1,193 @@
+package phonenumber_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hoflish/smspartner-go/v1/phonenumber"
+)
+
+func TestParse(t *testing.T) {
+	tests := [...]struct {
+		raw, region string
+		wantE164    string
+		wantRegion  string
+		wantType    phonenumber.Type
+	}{
+		// France, national and international notations
+		{"0612345678", "FR", "+33612345678", "FR", phonenumber.Mobile},
+		{"06 12 34 56 78", "FR", "+33612345678", "FR", phonenumber.Mobile},
+		{"06.12.34.56.78", "FR", "+33612345678", "FR", phonenumber.Mobile},
+		{"07-12-34-56-78", "FR", "+33712345678", "FR", phonenumber.Mobile},
+		{"+33 6 12 34 56 78", "", "+33612345678", "FR", phonenumber.Mobile},
+		{"+33 (0)6 12 34 56 78", "", "+33612345678", "FR", phonenumber.Mobile},
+		{"+330612345678", "", "+33612345678", "FR", phonenumber.Mobile},
+		{"0033612345678", "", "+33612345678", "FR", phonenumber.Mobile},
+		{"0033 1 23 45 67 89", "MA", "+33123456789", "FR", phonenumber.FixedLine},
+		{"tel:+33-1-23-45-67-89", "", "+33123456789", "FR", phonenumber.FixedLine},
+		{"01 23 45 67 89", "fr", "+33123456789", "FR", phonenumber.FixedLine},
+		{"0800 123 456", "FR", "+33800123456", "FR", phonenumber.TollFree},
+		{"0810 123 456", "FR", "+33810123456", "FR", phonenumber.SharedCost},
+		{"0899 123 456", "FR", "+33899123456", "FR", phonenumber.Premium},
+		{"09 12 34 56 78", "FR", "+33912345678", "FR", phonenumber.VoIP},
+
+		// overseas departments
+		{"0690 12 34 56", "FR", "+590690123456", "GP", phonenumber.Mobile},
+		{"0590 12 34 56", "FR", "+590590123456", "GP", phonenumber.FixedLine},
+		{"0696 12 34 56", "FR", "+596696123456", "MQ", phonenumber.Mobile},
+		{"0694 12 34 56", "FR", "+594694123456", "GF", phonenumber.Mobile},
+		{"0692 12 34 56", "FR", "+262692123456", "RE", phonenumber.Mobile},
+		{"0262 12 34 56", "FR", "+262262123456", "RE", phonenumber.FixedLine},
+		{"0639 12 34 56", "FR", "+262639123456", "YT", phonenumber.Mobile},
+		{"+262 269 12 34 56", "", "+262269123456", "YT", phonenumber.FixedLine},
+		{"+262 692 12 34 56", "", "+262692123456", "RE", phonenumber.Mobile},
+		{"0692123456", "RE", "+262692123456", "RE", phonenumber.Mobile},
+		{"0612345678", "RE", "+33612345678", "FR", phonenumber.Mobile},
+		{"+590 612345678", "FR", "+590612345678", "GP", phonenumber.Unknown},
+
+		// Europe
+		{"0470 12 34 56", "BE", "+32470123456", "BE", phonenumber.Mobile},
+		{"02 123 45 67", "BE", "+3221234567", "BE", phonenumber.FixedLine},
+		{"+41 79 123 45 67", "", "+41791234567", "CH", phonenumber.Mobile},
+		{"0151 23456789", "DE", "+4915123456789", "DE", phonenumber.Mobile},
+		{"030 1234567", "DE", "+49301234567", "DE", phonenumber.FixedLine},
+		{"612 34 56 78", "ES", "+34612345678", "ES", phonenumber.Mobile},
+		{"+34 912 34 56 78", "", "+34912345678", "ES", phonenumber.FixedLine},
+		{"312 345 6789", "IT", "+393123456789", "IT", phonenumber.Mobile},
+		{"06 1234 5678", "IT", "+390612345678", "IT", phonenumber.FixedLine},
+		{"912 345 678", "PT", "+351912345678", "PT", phonenumber.Mobile},
+		{"621 123 456", "LU", "+352621123456", "LU", phonenumber.Mobile},
+		{"06 12345678", "NL", "+31612345678", "NL", phonenumber.Mobile},
+		{"07700 900123", "GB", "+447700900123", "GB", phonenumber.Mobile},
+		{"+44 20 7946 0018", "", "+442079460018", "GB", phonenumber.FixedLine},
+
+		// Maghreb
+		{"0620-123456", "MA", "+212620123456", "MA", phonenumber.Mobile},
+		{"+212 520-123456", "", "+212520123456", "MA", phonenumber.FixedLine},
+		{"0550 12 34 56", "DZ", "+213550123456", "DZ", phonenumber.Mobile},
+		{"021 12 34 56", "DZ", "+21321123456", "DZ", phonenumber.FixedLine},
+		{"20 123 456", "TN", "+21620123456", "TN", phonenumber.Mobile},
+		{"+216 71 123 456", "", "+21671123456", "TN", phonenumber.FixedLine},
+	}
+
+	for i, tt := range tests {
+		p, err := phonenumber.Parse(tt.raw, tt.region)
+		if err != nil {
+			t.Errorf("#%d. %q: %v", i, tt.raw, err)
+			continue
+		}
+		if p.E164() != tt.wantE164 {
+			t.Errorf("#%d. %q: got: %s, want: %s", i, tt.raw, p.E164(), tt.wantE164)
+		}
+		if p.Region != tt.wantRegion {
+			t.Errorf("#%d. %q: got region: %s, want: %s", i, tt.raw, p.Region, tt.wantRegion)
+		}
+		if p.Type != tt.wantType {
+			t.Errorf("#%d. %q: got type: %s, want: %s", i, tt.raw, p.Type, tt.wantType)
+		}
+		if p.Raw != tt.raw {
+			t.Errorf("#%d. got raw: %q, want: %q", i, p.Raw, tt.raw)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := [...]struct {
+		raw, region string
+		wantErr     error
+	}{
+		{"", "FR", phonenumber.ErrInvalidNumber},
+		{"922264", "FR", phonenumber.ErrInvalidNumber},
+		{"612345678", "FR", phonenumber.ErrInvalidNumber},
+		{"06123456789", "FR", phonenumber.ErrInvalidNumber},
+		{"06 12 34 56 7a", "FR", phonenumber.ErrInvalidNumber},
+		{"06+12345678", "FR", phonenumber.ErrInvalidNumber},
+		{"+1 202 555 0100", "", phonenumber.ErrUnknownRegion},
+		{"0612345678", "US", phonenumber.ErrUnknownRegion},
+		{"0612345678", "", phonenumber.ErrUnknownRegion},
+	}
+
+	for i, tt := range tests {
+		_, err := phonenumber.Parse(tt.raw, tt.region)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("#%d. %q: got: %v, want: %v", i, tt.raw, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := [...]struct {
+		raw, region string
+		want        phonenumber.Format
+	}{
+		// as returned by the /lookup endpoint
+		{"+212620123456", "", phonenumber.Format{
+			E164:          "+212620123456",
+			International: "+212 620-123456",
+			National:      "0620-123456",
+			RFC3966:       "tel:+212-620-123456",
+		}},
+		{"0612345678", "FR", phonenumber.Format{
+			E164:          "+33612345678",
+			International: "+33 6 12 34 56 78",
+			National:      "06 12 34 56 78",
+			RFC3966:       "tel:+33-6-12-34-56-78",
+		}},
+		{"0692123456", "FR", phonenumber.Format{
+			E164:          "+262692123456",
+			International: "+262 692 12 34 56",
+			National:      "0692 12 34 56",
+			RFC3966:       "tel:+262-692-12-34-56",
+		}},
+		{"612345678", "ES", phonenumber.Format{
+			E164:          "+34612345678",
+			International: "+34 612 34 56 78",
+			National:      "612 34 56 78",
+			RFC3966:       "tel:+34-612-34-56-78",
+		}},
+		{"0151 23456789", "DE", phonenumber.Format{
+			E164:          "+4915123456789",
+			International: "+49 151 23456789",
+			National:      "0151 23456789",
+			RFC3966:       "tel:+49-151-23456789",
+		}},
+		// no grouping for German landlines, whose area codes vary in length
+		{"030 1234567", "DE", phonenumber.Format{
+			E164:          "+49301234567",
+			International: "+49 301234567",
+			National:      "0301234567",
+			RFC3966:       "tel:+49-301234567",
+		}},
+	}
+
+	for i, tt := range tests {
+		p, err := phonenumber.Parse(tt.raw, tt.region)
+		if err != nil {
+			t.Fatalf("#%d. %v", i, err)
+		}
+		if got := p.Format(); got != tt.want {
+			t.Errorf("#%d. got: %+v, want: %+v", i, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	got, err := phonenumber.Normalize("06 20 12 34 56", "FR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "+33620123456"; got != want {
+		t.Errorf("got: %s, want: %s", got, want)
+	}
+}
+
+func TestRegions(t *testing.T) {
+	for _, region := range phonenumber.Regions() {
+		if phonenumber.CountryCode(region) == 0 {
+			t.Errorf("%s: no country code", region)
+		}
+	}
+	if got := phonenumber.CountryCode("yt"); got != 262 {
+		t.Errorf("got: %d, want: %d", got, 262)
+	}
+}
@@ -0,0 +1,118 @@
+package smspartner
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/hoflish/smspartner-go/v1/phonenumber"
+)
+
+// NumberFilter removes from bulk SMS the recipients whose number is invalid
+// or not of one of Types. The numbers are classified offline first, and with
+// LookupNumbers when their type can not be told offline. See the
+// FilterNumbers option.
+type NumberFilter struct {
+	Types  []phonenumber.Type // defaults to phonenumber.Mobile
+	Region string             // region of national numbers, defaults to "FR"
+
+	// Offline disables the lookups, keeping the numbers of unknown type.
+	Offline bool
+
+	client *Client
+}
+
+// NewNumberFilter returns a filter keeping the numbers of types, looked up
+// with c.
+func (c *Client) NewNumberFilter(types ...phonenumber.Type) *NumberFilter {
+	return &NumberFilter{Types: types, client: c}
+}
+
+func (f *NumberFilter) region() string {
+	if f.Region == "" {
+		return "FR"
+	}
+	return f.Region
+}
+
+func (f *NumberFilter) allowed(t phonenumber.Type) bool {
+	if len(f.Types) == 0 {
+		return t == phonenumber.Mobile
+	}
+	for _, allowed := range f.Types {
+		if t == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupType returns the type of a lookup, written as by Type.String.
+func lookupType(l *Lookup) phonenumber.Type {
+	for t := phonenumber.FixedLine; t <= phonenumber.VoIP; t++ {
+		if strings.EqualFold(l.Type, t.String()) {
+			return t
+		}
+	}
+	return phonenumber.Unknown
+}
+
+// FilterBulkSMS removes the recipients of bulksms whose number is invalid or
+// of another type, and returns them.
+func (f *NumberFilter) FilterBulkSMS(ctx context.Context, bulksms *BulkSMS) ([]*ExcludedRecipient, error) {
+	types := make([]phonenumber.Type, len(bulksms.SMSList))
+	invalid := make([]bool, len(bulksms.SMSList))
+	var unknown []string
+	for i, sms := range bulksms.SMSList {
+		if sms == nil {
+			continue
+		}
+		p, err := phonenumber.Parse(sms.PhoneNumber, f.region())
+		switch {
+		case errors.Is(err, phonenumber.ErrInvalidNumber):
+			invalid[i] = true
+		case err == nil && p.Type != phonenumber.Unknown:
+			types[i] = p.Type
+		default:
+			unknown = append(unknown, sms.PhoneNumber)
+		}
+	}
+
+	var lookups map[string]*LookupResult
+	if len(unknown) > 0 && !f.Offline {
+		lookups = f.client.LookupNumbers(ctx, unknown...)
+	}
+
+	var excluded []*ExcludedRecipient
+	kept := make([]*SMSPayload, 0, len(bulksms.SMSList))
+	for i, sms := range bulksms.SMSList {
+		if sms == nil {
+			continue
+		}
+		t := types[i]
+		if res, ok := lookups[sms.PhoneNumber]; ok {
+			var lookupErr *LookupError
+			switch {
+			case errors.As(res.Err, &lookupErr):
+				invalid[i] = true
+			case res.Err != nil:
+				return nil, res.Err
+			default:
+				t = lookupType(res.Lookup)
+			}
+		}
+
+		switch {
+		case invalid[i]:
+			excluded = append(excluded, &ExcludedRecipient{PhoneNumber: sms.PhoneNumber, Reason: ExcludedInvalid, Detail: "invalid phone number"})
+		case t == phonenumber.Unknown && f.Offline:
+			kept = append(kept, sms)
+		case !f.allowed(t):
+			excluded = append(excluded, &ExcludedRecipient{PhoneNumber: sms.PhoneNumber, Reason: ExcludedNumberType, Detail: t.String()})
+		default:
+			kept = append(kept, sms)
+		}
+	}
+	bulksms.SMSList = kept
+	return excluded, nil
+}
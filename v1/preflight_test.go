@@ -0,0 +1,125 @@
+package smspartner_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hoflish/smspartner-go/v1"
+	"github.com/hoflish/smspartner-go/v1/phonenumber"
+)
+
+func TestNumberFilter(t *testing.T) {
+	h := &lookupHandler{t: t}
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	bulk := &smspartner.BulkSMS{SMSList: []*smspartner.SMSPayload{
+		{PhoneNumber: "0620123456", Message: "Hello"},
+		{PhoneNumber: "01 23 45 67 89", Message: "Hello"},
+		{PhoneNumber: "12", Message: "Hello"},
+		{PhoneNumber: "+12025550100", Message: "Hello"}, // looked up: mobile
+		{PhoneNumber: "+12025550199", Message: "Hello"}, // looked up: invalid
+	}}
+	filter := cli.NewNumberFilter()
+	excluded, err := filter.FilterBulkSMS(context.Background(), bulk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.requests != 1 {
+		t.Errorf("got %d lookup requests, want: 1", h.requests)
+	}
+
+	var kept []string
+	for _, sms := range bulk.SMSList {
+		kept = append(kept, sms.PhoneNumber)
+	}
+	if len(kept) != 2 || kept[0] != "0620123456" || kept[1] != "+12025550100" {
+		t.Errorf("got recipients: %v", kept)
+	}
+
+	want := []struct {
+		number string
+		reason smspartner.ExclusionReason
+	}{
+		{"01 23 45 67 89", smspartner.ExcludedNumberType},
+		{"12", smspartner.ExcludedInvalid},
+		{"+12025550199", smspartner.ExcludedInvalid},
+	}
+	if len(excluded) != len(want) {
+		t.Fatalf("got %d excluded recipients, want: %d", len(excluded), len(want))
+	}
+	for i, w := range want {
+		if excluded[i].PhoneNumber != w.number || excluded[i].Reason != w.reason {
+			t.Errorf("got excluded: %+v, want: %s (%s)", excluded[i], w.number, w.reason)
+		}
+	}
+	if excluded[0].Detail != phonenumber.FixedLine.String() {
+		t.Errorf("got detail: %q, want: %q", excluded[0].Detail, phonenumber.FixedLine.String())
+	}
+}
+
+func TestFilterNumbersAllExcluded(t *testing.T) {
+	h := &lookupHandler{t: t}
+	cli, teardown := testingHTTPClient(t, h, smspartner.FilterNumbers(&smspartner.NumberFilter{}))
+	defer teardown()
+
+	_, err := cli.SendBulkSMS(&smspartner.BulkSMS{SMSList: []*smspartner.SMSPayload{
+		{PhoneNumber: "01 23 45 67 89", Message: "Hello"},
+	}})
+	var allExcluded *smspartner.AllRecipientsExcludedError
+	if !errors.As(err, &allExcluded) || len(allExcluded.Excluded) != 1 {
+		t.Errorf("got error: %v, want: an *AllRecipientsExcludedError", err)
+	}
+	if h.requests != 0 {
+		t.Errorf("got %d requests, want: 0", h.requests)
+	}
+}
+
+func TestFilterNumbersAccount(t *testing.T) {
+	var apiKeys []string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/lookup":
+			var req smspartner.NumberVerificationRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			apiKeys = append(apiKeys, req.APIKey)
+			json.NewEncoder(w).Encode(&smspartner.LookupResponse{Success: true, Code: 200, Lookup: []*smspartner.Lookup{
+				{Request: req.PhoneNumbers, Success: true, PhoneNumber: req.PhoneNumbers, Type: "Mobile"},
+			}})
+		case "/v1/bulk-send":
+			b, err := fixture("send_bulksms.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write(b)
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+		}
+	})
+
+	// one filter for two clients
+	filter := &smspartner.NumberFilter{}
+	cli, teardown := testingHTTPClient(t, h, smspartner.APIKey("main-api-key"), smspartner.FilterNumbers(filter))
+	defer teardown()
+	other, teardown := testingHTTPClient(t, h, smspartner.APIKey("other-api-key"), smspartner.FilterNumbers(filter))
+	defer teardown()
+	sub, err := cli.ForSubAccount(&smspartner.SubAccount{Token: "a1b2c3", APIKey: "sub-api-key-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []*smspartner.Client{cli, other, sub} {
+		bulk := &smspartner.BulkSMS{SMSList: []*smspartner.SMSPayload{{PhoneNumber: "+12025550100", Message: "Hello"}}}
+		if _, err := c.SendBulkSMS(bulk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"main-api-key", "other-api-key", "sub-api-key-1"}; fmt.Sprint(apiKeys) != fmt.Sprint(want) {
+		t.Errorf("lookups made with: %v, want: %v", apiKeys, want)
+	}
+}
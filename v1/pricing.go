@@ -0,0 +1,403 @@
+package smspartner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hoflish/smspartner-go/v1/phonenumber"
+)
+
+// AnyCountry is the country of the prices applying to the countries
+// missing from a PriceTable.
+const AnyCountry = "*"
+
+// NoPriceError is returned when a PriceTable has no price for a recipient.
+type NoPriceError struct {
+	PhoneNumber string
+	Country     string // empty if unknown
+	Gamme       Gamme
+}
+
+func (e *NoPriceError) Error() string {
+	country := e.Country
+	if country == "" {
+		country = "unknown country"
+	}
+	return fmt.Sprintf("no price for %s (%s) in gamme %d", e.PhoneNumber, country, e.Gamme)
+}
+
+// PriceTable is a Pricer holding the price of a segment per country and
+// gamme, e.g. loaded from the price list of the account.
+type PriceTable struct {
+	Currency string
+	Region   string // region of national numbers, defaults to "FR"
+
+	prices map[string]map[Gamme]Money
+}
+
+// NewPriceTable returns an empty table of prices in currency.
+func NewPriceTable(currency string) *PriceTable {
+	return &PriceTable{Currency: currency, prices: map[string]map[Gamme]Money{}}
+}
+
+// LoadPriceTable reads a price table from a .csv or .json file, see
+// ReadPriceTableCSV and ReadPriceTableJSON.
+func LoadPriceTable(path, currency string) (*PriceTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var t *PriceTable
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		t, err = ReadPriceTableCSV(f, currency)
+	case ".json":
+		t, err = ReadPriceTableJSON(f, currency)
+	default:
+		return nil, fmt.Errorf("unknown price table format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading price table %s: %v", path, err)
+	}
+	return t, nil
+}
+
+// ReadPriceTableCSV reads a price table with the columns country, gamme,
+// price and optionally currency, after a header line, e.g.
+//
+//	country,gamme,price,currency
+//	FR,premium,0.045,EUR
+//	FR,lowcost,0.035,EUR
+//	*,premium,0.09,EUR
+//
+// The currency defaults to currency.
+func ReadPriceTableCSV(r io.Reader, currency string) (*PriceTable, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("missing header")
+	}
+
+	cols := map[string]int{}
+	for i, name := range records[0] {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"country", "gamme", "price"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	t := NewPriceTable(currency)
+	for i, rec := range records[1:] {
+		field := func(name string) string {
+			if j, ok := cols[name]; ok && j < len(rec) {
+				return strings.TrimSpace(rec[j])
+			}
+			return ""
+		}
+		if err := t.set(field("country"), field("gamme"), field("price"), field("currency")); err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+2, err)
+		}
+	}
+	return t, nil
+}
+
+// ReadPriceTableJSON reads a price table in JSON, e.g.
+//
+//	{"currency": "EUR", "prices": [
+//		{"country": "FR", "gamme": "premium", "price": 0.045},
+//		{"country": "*", "gamme": 1, "price": "0.09"}
+//	]}
+//
+// The currency defaults to currency.
+func ReadPriceTableJSON(r io.Reader, currency string) (*PriceTable, error) {
+	var f struct {
+		Currency string `json:"currency"`
+		Prices   []struct {
+			Country  string          `json:"country"`
+			Gamme    json.RawMessage `json:"gamme"`
+			Price    json.RawMessage `json:"price"`
+			Currency string          `json:"currency"`
+		} `json:"prices"`
+	}
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	if f.Currency != "" {
+		currency = f.Currency
+	}
+
+	t := NewPriceTable(currency)
+	for i, p := range f.Prices {
+		if err := t.set(p.Country, unquote(p.Gamme), unquote(p.Price), p.Currency); err != nil {
+			return nil, fmt.Errorf("price %d: %v", i, err)
+		}
+	}
+	return t, nil
+}
+
+func unquote(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func (t *PriceTable) set(country, gamme, price, currency string) error {
+	g, err := parseGamme(gamme)
+	if err != nil {
+		return err
+	}
+	if currency == "" {
+		currency = t.Currency
+	}
+	if t.Currency == "" {
+		t.Currency = currency
+	}
+	if currency != t.Currency {
+		return fmt.Errorf("price in %s in a table in %s", currency, t.Currency)
+	}
+	m, err := ParseMoney(price, currency)
+	if err != nil {
+		return err
+	}
+	if country == "" {
+		return fmt.Errorf("missing country")
+	}
+	t.Set(country, g, m)
+	return nil
+}
+
+func parseGamme(s string) (Gamme, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "premium":
+		return Premium, nil
+	case "lowcost", "low-cost", "low cost":
+		return LowCost, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || (Gamme(n) != Premium && Gamme(n) != LowCost) {
+		return 0, fmt.Errorf("invalid gamme %q", s)
+	}
+	return Gamme(n), nil
+}
+
+// Set sets the price of a segment sent to country (e.g. "FR", or AnyCountry)
+// in gamme.
+func (t *PriceTable) Set(country string, gamme Gamme, price Money) {
+	if gamme == 0 {
+		gamme = Premium
+	}
+	country = strings.ToUpper(country)
+	if t.prices == nil {
+		t.prices = map[string]map[Gamme]Money{}
+	}
+	if t.prices[country] == nil {
+		t.prices[country] = map[Gamme]Money{}
+	}
+	if price.Currency == "" {
+		price.Currency = t.Currency
+	}
+	t.prices[country][gamme] = price
+}
+
+func (t *PriceTable) region() string {
+	if t.Region == "" {
+		return "FR"
+	}
+	return t.Region
+}
+
+// Price returns the price of a segment sent to phoneNumber in gamme: the
+// price of its country, or else of AnyCountry. It fails with a
+// *NoPriceError if there is none.
+func (t *PriceTable) Price(phoneNumber string, gamme Gamme) (Money, error) {
+	if gamme == 0 {
+		gamme = Premium
+	}
+	country := recipientCountry(phoneNumber, t.region())
+	for _, c := range []string{country, AnyCountry} {
+		if price, ok := t.prices[c][gamme]; ok && c != "" {
+			return price, nil
+		}
+	}
+	return Money{}, &NoPriceError{PhoneNumber: phoneNumber, Country: country, Gamme: gamme}
+}
+
+// recipientCountry returns the region of phoneNumber, or an empty string.
+func recipientCountry(phoneNumber, region string) string {
+	p, err := phonenumber.Parse(phoneNumber, region)
+	if err != nil {
+		return ""
+	}
+	return p.Region
+}
+
+// EstimateLine is the estimated cost of the SMS of a recipient.
+type EstimateLine struct {
+	PhoneNumber string
+	Country     string // empty if unknown
+	Segments    int
+	Price       Money // of a segment
+	Cost        Money
+}
+
+// CostEstimate is the estimated cost of an SMS or a bulk SMS.
+type CostEstimate struct {
+	Total Money
+	Lines []*EstimateLine
+}
+
+func (est *CostEstimate) add(p Pricer, phoneNumber, message string, gamme Gamme) error {
+	price, err := p.Price(phoneNumber, gamme)
+	if err != nil {
+		return err
+	}
+	line := &EstimateLine{
+		PhoneNumber: phoneNumber,
+		Country:     recipientCountry(phoneNumber, "FR"),
+		Segments:    CountSegments(message).Segments,
+		Price:       price,
+	}
+	line.Cost = price.Mul(int64(line.Segments))
+	if est.Total, err = est.Total.Add(line.Cost); err != nil {
+		return err
+	}
+	est.Lines = append(est.Lines, line)
+	return nil
+}
+
+// EstimateSMSCost returns the estimated cost of sms, per recipient.
+func EstimateSMSCost(p Pricer, sms *SMS) (*CostEstimate, error) {
+	est := new(CostEstimate)
+	for _, number := range splitPhoneNumbers(sms.PhoneNumbers) {
+		if err := est.add(p, number, sms.Message, sms.Gamme); err != nil {
+			return nil, err
+		}
+	}
+	return est, nil
+}
+
+// EstimateBulkSMSCost returns the estimated cost of bulksms, per recipient.
+// The nil SMS of SMSList are not estimated.
+func EstimateBulkSMSCost(p Pricer, bulksms *BulkSMS) (*CostEstimate, error) {
+	est := new(CostEstimate)
+	for _, sms := range bulksms.SMSList {
+		if sms == nil {
+			continue
+		}
+		if err := est.add(p, sms.PhoneNumber, sms.Message, bulksms.Gamme); err != nil {
+			return nil, err
+		}
+	}
+	return est, nil
+}
+
+// ReconciliationLine compares the estimated and actual cost of a recipient,
+// or of a country.
+type ReconciliationLine struct {
+	PhoneNumber string
+	Country     string
+	Estimated   Money
+	Actual      Money
+	Difference  Money // Actual - Estimated
+}
+
+// Reconciliation compares a CostEstimate with the costs returned by the API.
+// Lines is empty when the response has no cost per recipient.
+type Reconciliation struct {
+	Estimated  Money
+	Actual     Money
+	Difference Money // Actual - Estimated
+	Lines      []*ReconciliationLine
+}
+
+// ReconcileSMS compares est with the cost of resp, the response of the SMS
+// it estimates.
+func ReconcileSMS(est *CostEstimate, resp *SMSResponse) (*Reconciliation, error) {
+	return reconcile(est, resp.Cost, nil)
+}
+
+// ReconcileBulkSMS compares est with the costs of resp, the response of the
+// bulk SMS it estimates.
+func ReconcileBulkSMS(est *CostEstimate, resp *BulkSMSResponse) (*Reconciliation, error) {
+	return reconcile(est, resp.Cost, pairedResponses(resp, len(est.Lines)))
+}
+
+func reconcile(est *CostEstimate, actual Money, responses []*SMSResponse) (*Reconciliation, error) {
+	diff, err := actual.Sub(est.Total)
+	if err != nil {
+		return nil, err
+	}
+	rec := &Reconciliation{Estimated: est.Total, Actual: actual, Difference: diff}
+	for i, r := range responses {
+		if r == nil {
+			continue
+		}
+		l := est.Lines[i]
+		diff, err := r.Cost.Sub(l.Cost)
+		if err != nil {
+			return nil, err
+		}
+		rec.Lines = append(rec.Lines, &ReconciliationLine{
+			PhoneNumber: l.PhoneNumber,
+			Country:     l.Country,
+			Estimated:   l.Cost,
+			Actual:      r.Cost,
+			Difference:  diff,
+		})
+	}
+	return rec, nil
+}
+
+// Mismatches returns the lines whose actual cost differs from the estimate.
+func (rec *Reconciliation) Mismatches() []*ReconciliationLine {
+	var lines []*ReconciliationLine
+	for _, l := range rec.Lines {
+		if !l.Difference.IsZero() {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// ByCountry sums the lines per country, ordered by country, e.g. to find the
+// prices of the table that are out of date.
+func (rec *Reconciliation) ByCountry() []*ReconciliationLine {
+	byCountry := map[string]*ReconciliationLine{}
+	var countries []string
+	for _, l := range rec.Lines {
+		c, ok := byCountry[l.Country]
+		if !ok {
+			c = &ReconciliationLine{Country: l.Country}
+			byCountry[l.Country] = c
+			countries = append(countries, l.Country)
+		}
+		c.Estimated, _ = c.Estimated.Add(l.Estimated)
+		c.Actual, _ = c.Actual.Add(l.Actual)
+		c.Difference, _ = c.Difference.Add(l.Difference)
+	}
+
+	sort.Strings(countries)
+	lines := make([]*ReconciliationLine, len(countries))
+	for i, c := range countries {
+		lines[i] = byCountry[c]
+	}
+	return lines
+}
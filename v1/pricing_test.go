@@ -0,0 +1,120 @@
+package smspartner_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+func TestPriceTable(t *testing.T) {
+	prices, err := smspartner.LoadPriceTable("testdata/prices.csv", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		number string
+		gamme  smspartner.Gamme
+		want   string
+	}{
+		{"0620123456", 0, "0.045 EUR"},
+		{"+33620123456", smspartner.LowCost, "0.038 EUR"},
+		{"+32470123456", smspartner.Premium, "0.07 EUR"},
+		{"+447700900123", smspartner.Premium, "0.09 EUR"},
+	} {
+		got, err := prices.Price(tc.number, tc.gamme)
+		if err != nil {
+			t.Errorf("%s: %v", tc.number, err)
+			continue
+		}
+		if got.String() != tc.want {
+			t.Errorf("%s: got: %s, want: %s", tc.number, got, tc.want)
+		}
+	}
+
+	_, err = prices.Price("+32470123456", smspartner.LowCost)
+	var noPrice *smspartner.NoPriceError
+	if !errors.As(err, &noPrice) || noPrice.Country != "BE" {
+		t.Errorf("got: %v, want: a *NoPriceError for BE", err)
+	}
+}
+
+func TestReadPriceTableJSON(t *testing.T) {
+	prices, err := smspartner.ReadPriceTableJSON(strings.NewReader(`{"currency": "EUR", "prices": [
+		{"country": "FR", "gamme": 2, "price": "0,038"},
+		{"country": "*", "gamme": "premium", "price": 0.09}
+	]}`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := prices.Price("0620123456", smspartner.LowCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "0.038 EUR" {
+		t.Errorf("got: %s, want: %s", got, "0.038 EUR")
+	}
+
+	_, err = smspartner.ReadPriceTableJSON(strings.NewReader(`{"prices": [{"country": "FR", "gamme": "gold", "price": 1}]}`), "EUR")
+	if err == nil {
+		t.Error("expected an error for an invalid gamme")
+	}
+}
+
+func TestReconcileBulkSMS(t *testing.T) {
+	prices, err := smspartner.LoadPriceTable("testdata/prices.csv", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bulk := &smspartner.BulkSMS{
+		Gamme: smspartner.LowCost,
+		SMSList: []*smspartner.SMSPayload{
+			{PhoneNumber: "0620123456", Message: "Hello"},
+			{PhoneNumber: "0621123456", Message: strings.Repeat("a", 161)},
+		},
+	}
+	est, err := smspartner.EstimateBulkSMSCost(prices, bulk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.Total.String() != "0.114 EUR" || len(est.Lines) != 2 || est.Lines[1].Segments != 2 {
+		t.Fatalf("unexpected estimate: %s, %d lines", est.Total, len(est.Lines))
+	}
+
+	b, err := fixture("send_bulksms.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := new(smspartner.BulkSMSResponse)
+	if err := json.Unmarshal(b, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := smspartner.ReconcileBulkSMS(est, resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Difference.String() != "-0.038 EUR" {
+		t.Errorf("got difference: %s, want: %s", rec.Difference, "-0.038 EUR")
+	}
+	mismatches := rec.Mismatches()
+	if len(mismatches) != 1 || mismatches[0].PhoneNumber != "0621123456" {
+		t.Errorf("unexpected mismatches: %+v", mismatches)
+	}
+	byCountry := rec.ByCountry()
+	if len(byCountry) != 1 || byCountry[0].Country != "FR" || byCountry[0].Actual.String() != "0.076 EUR" {
+		t.Errorf("unexpected totals by country: %+v", byCountry)
+	}
+
+	// the nil SMS of an unvalidated list are not estimated
+	bulk.SMSList = append(bulk.SMSList, nil)
+	if est, err = smspartner.EstimateBulkSMSCost(prices, bulk); err != nil {
+		t.Fatal(err)
+	}
+	if est.Total.String() != "0.114 EUR" || len(est.Lines) != 2 {
+		t.Errorf("unexpected estimate: %s, %d lines", est.Total, len(est.Lines))
+	}
+}
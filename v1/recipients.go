@@ -0,0 +1,164 @@
+package smspartner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hoflish/smspartner-go/v1/phonenumber"
+)
+
+// MaxRecipients is the maximum number of phone numbers of a single request.
+const MaxRecipients = 500
+
+var ErrTooManyRecipients = fmt.Errorf("A request can not have more than %d recipients", MaxRecipients)
+
+// Recipient is a normalized phone number and the inputs it was parsed from.
+type Recipient struct {
+	PhoneNumber string   // E.164
+	Inputs      []string // e.g. "06 20 12 34 56" and "+33620123456"
+}
+
+// Recipients is a list of distinct phone numbers, sent to the API as a
+// comma-separated string.
+type Recipients []*Recipient
+
+// InvalidRecipient is an input that is not a valid phone number.
+type InvalidRecipient struct {
+	Input string
+	Err   error
+}
+
+// InvalidRecipientsError is returned by ParseRecipients when some inputs are
+// not valid phone numbers.
+type InvalidRecipientsError struct {
+	Invalid []*InvalidRecipient
+}
+
+func (e *InvalidRecipientsError) Error() string {
+	msg := fmt.Sprintf("invalid phone number %q", e.Invalid[0].Input)
+	switch n := len(e.Invalid); n {
+	case 1:
+		return msg
+	case 2:
+		return msg + " (and 1 other error)"
+	default:
+		return fmt.Sprintf("%s (and %d other errors)", msg, n-1)
+	}
+}
+
+// ParseRecipients normalizes inputs to E.164, national numbers being read
+// in defaultRegion (e.g. "FR"), and removes duplicates. An input may hold
+// several comma-separated numbers. It fails with an *InvalidRecipientsError
+// if an input is not a phone number, and with ErrTooManyRecipients if there
+// are more than MaxRecipients distinct numbers.
+func ParseRecipients(defaultRegion string, inputs ...string) (Recipients, error) {
+	var r Recipients
+	byNumber := map[string]*Recipient{}
+	invalid := new(InvalidRecipientsError)
+
+	for _, in := range inputs {
+		for _, p := range strings.Split(in, ",") {
+			if strings.TrimSpace(p) == "" {
+				continue
+			}
+			number, err := normalizePhoneNumber(p, defaultRegion)
+			if err != nil {
+				invalid.Invalid = append(invalid.Invalid, &InvalidRecipient{Input: p, Err: err})
+				continue
+			}
+			rcpt, ok := byNumber[number]
+			if !ok {
+				rcpt = &Recipient{PhoneNumber: number}
+				byNumber[number] = rcpt
+				r = append(r, rcpt)
+			}
+			rcpt.Inputs = append(rcpt.Inputs, p)
+		}
+	}
+
+	if len(invalid.Invalid) > 0 {
+		return nil, invalid
+	}
+	if len(r) > MaxRecipients {
+		return nil, ErrTooManyRecipients
+	}
+	return r, nil
+}
+
+// normalizePhoneNumber returns p in E.164 format. International numbers of
+// countries unknown to the phonenumber package are only cleaned up.
+func normalizePhoneNumber(p, defaultRegion string) (string, error) {
+	number, err := phonenumber.Normalize(p, defaultRegion)
+	if errors.Is(err, phonenumber.ErrUnknownRegion) {
+		s := strings.TrimSpace(p)
+		if (strings.HasPrefix(s, "+") || strings.HasPrefix(s, "00")) && validPhoneNumber(s) {
+			s = strings.TrimPrefix(strings.TrimPrefix(s, "+"), "00")
+			return "+" + strings.NewReplacer(" ", "", ".", "", "-", "").Replace(s), nil
+		}
+	}
+	return number, err
+}
+
+// Numbers returns the normalized phone numbers.
+func (r Recipients) Numbers() []string {
+	numbers := make([]string, len(r))
+	for i, rcpt := range r {
+		numbers[i] = rcpt.PhoneNumber
+	}
+	return numbers
+}
+
+// String returns the numbers in the comma-separated format of the API.
+func (r Recipients) String() string {
+	return strings.Join(r.Numbers(), ",")
+}
+
+// MarshalJSON encodes the recipients in the comma-separated format of the API.
+func (r Recipients) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON decodes comma-separated E.164 numbers.
+func (r *Recipients) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*r = nil
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			*r = append(*r, &Recipient{PhoneNumber: p, Inputs: []string{p}})
+		}
+	}
+	return nil
+}
+
+// Inputs returns the inputs normalized to phoneNumber, which may be written
+// in any format as long as it is in E.164 or international format.
+func (r Recipients) Inputs(phoneNumber string) []string {
+	number, err := normalizePhoneNumber(phoneNumber, "")
+	if err != nil {
+		number = strings.TrimSpace(phoneNumber)
+	}
+	for _, rcpt := range r {
+		if rcpt.PhoneNumber == number {
+			return rcpt.Inputs
+		}
+	}
+	return nil
+}
+
+// LookupByInput maps each input of r to its result in resp, the response of
+// VerifyNumberFormat(r.Numbers()...). Inputs missing from the response are
+// absent from the map.
+func (r Recipients) LookupByInput(resp *LookupResponse) map[string]*Lookup {
+	m := map[string]*Lookup{}
+	for _, l := range resp.Lookup {
+		for _, in := range r.Inputs(l.Request) {
+			m[in] = l
+		}
+	}
+	return m
+}
@@ -0,0 +1,126 @@
+package smspartner_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+func TestParseRecipients(t *testing.T) {
+	r, err := smspartner.ParseRecipients("FR",
+		"06 20 12 34 56",
+		"+33620123456, 0033 6 21 12 34 56",
+		"+212620123456",
+		"+1 202 555 0100",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantNumbers := []string{"+33620123456", "+33621123456", "+212620123456", "+12025550100"}
+	if got := r.Numbers(); !reflect.DeepEqual(got, wantNumbers) {
+		t.Errorf("got: %v, want: %v", got, wantNumbers)
+	}
+	if got, want := r.Inputs("+33 6 20 12 34 56"), []string{"06 20 12 34 56", "+33620123456"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	b, err := json.Marshal(&smspartner.SMS{PhoneNumbers: r.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"phoneNumbers":"+33620123456,+33621123456,+212620123456,+12025550100"}`; string(b) != want {
+		t.Errorf("got: %s, want: %s", b, want)
+	}
+}
+
+func TestParseRecipientsErrors(t *testing.T) {
+	_, err := smspartner.ParseRecipients("FR", "0620123456", "922264", "06 abc")
+
+	var invalidErr *smspartner.InvalidRecipientsError
+	if !errors.As(err, &invalidErr) {
+		t.Fatalf("got: %v, want an *InvalidRecipientsError", err)
+	}
+	if len(invalidErr.Invalid) != 2 || invalidErr.Invalid[0].Input != "922264" {
+		t.Errorf("unexpected invalid recipients: %v", err)
+	}
+
+	inputs := make([]string, smspartner.MaxRecipients+1)
+	for i := range inputs {
+		inputs[i] = fmt.Sprintf("06%08d", i)
+	}
+	if _, err := smspartner.ParseRecipients("FR", inputs...); err != smspartner.ErrTooManyRecipients {
+		t.Errorf("got: %v, want: %v", err, smspartner.ErrTooManyRecipients)
+	}
+	// duplicates do not count
+	if _, err := smspartner.ParseRecipients("FR", append(inputs[:smspartner.MaxRecipients], "+33600000000")...); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecipientsLookupByInput(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		b, err := fixture("lookup.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	r, err := smspartner.ParseRecipients("MA", "0620-123456", "+212 666 123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := cli.VerifyNumberFormat(r.Numbers()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byInput := r.LookupByInput(res)
+	if l := byInput["0620-123456"]; l == nil || !l.Success {
+		t.Errorf("got: %#v, want a successful lookup", l)
+	}
+	if l := byInput["+212 666 123456"]; l == nil || l.Success || l.Message == "" {
+		t.Errorf("got: %#v, want a failed lookup", l)
+	}
+}
+
+func TestSendSMSRecipients(t *testing.T) {
+	var got smspartner.SMS
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		b, err := fixture("send_sms.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	r, err := smspartner.ParseRecipients("FR", "06 20 12 34 56", "0620123456", "07 21 12 34 56")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.SendSMS(&smspartner.SMS{Recipients: r, Message: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "+33620123456,+33721123456"; got.PhoneNumbers != want {
+		t.Errorf("got: %s, want: %s", got.PhoneNumbers, want)
+	}
+}
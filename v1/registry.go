@@ -0,0 +1,279 @@
+package smspartner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// templateExt is the extension of the template files loaded by LoadTemplates.
+const templateExt = ".txt"
+
+var ErrNoTemplates = errors.New("No template registry, see the Templates option")
+
+// TemplateRegistry holds named message templates translated in several
+// locales, e.g. the "otp" template in "fr" and "en".
+type TemplateRegistry struct {
+	fallback  string
+	templates map[string]map[string]*MessageTemplate // name -> locale -> template
+}
+
+// NewTemplateRegistry returns an empty registry. Templates missing in the
+// requested locale are looked up in the fallback locale.
+func NewTemplateRegistry(fallback string) *TemplateRegistry {
+	return &TemplateRegistry{
+		fallback:  normalizeLocale(fallback),
+		templates: map[string]map[string]*MessageTemplate{},
+	}
+}
+
+// LoadTemplates loads the templates of fsys, laid out as <locale>/<name>.txt:
+//
+//	fr/otp.txt
+//	en/otp.txt
+//	en/delivery_notice.txt
+func LoadTemplates(fsys fs.FS, fallback string) (*TemplateRegistry, error) {
+	reg := NewTemplateRegistry(fallback)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != templateExt {
+			return nil
+		}
+		locale, file := path.Split(p)
+		if locale == "" || strings.Count(p, "/") != 1 {
+			return fmt.Errorf("template %s: expected <locale>/<name>%s", p, templateExt)
+		}
+
+		text, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(file, templateExt)
+		return reg.Add(name, strings.TrimSuffix(locale, "/"), strings.TrimRight(string(text), "\r\n"))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// LoadTemplateDir loads the templates of the directory dir, see LoadTemplates.
+func LoadTemplateDir(dir, fallback string) (*TemplateRegistry, error) {
+	return LoadTemplates(os.DirFS(dir), fallback)
+}
+
+// Add parses text and registers it as the template name in locale.
+func (r *TemplateRegistry) Add(name, locale, text string) error {
+	tpl, err := ParseTemplate(text)
+	if err != nil {
+		return fmt.Errorf("template %s (%s): %v", name, locale, err)
+	}
+	if r.templates[name] == nil {
+		r.templates[name] = map[string]*MessageTemplate{}
+	}
+	r.templates[name][normalizeLocale(locale)] = tpl
+	return nil
+}
+
+// normalizeLocale turns "fr_FR" into "fr-fr".
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// Lookup returns the template name in locale, falling back to the base
+// language ("fr" for "fr-CA") and then to the fallback locale. The locale
+// of the returned template is returned as well.
+func (r *TemplateRegistry) Lookup(name, locale string) (*MessageTemplate, string, error) {
+	locales, ok := r.templates[name]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown template %q", name)
+	}
+
+	locale = normalizeLocale(locale)
+	candidates := []string{locale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	candidates = append(candidates, r.fallback)
+
+	for _, l := range candidates {
+		if tpl, ok := locales[l]; ok {
+			return tpl, l, nil
+		}
+	}
+	return nil, "", fmt.Errorf("template %q is not available in %q nor %q", name, locale, r.fallback)
+}
+
+// Render renders the template name in locale. params is either a map with
+// string keys or a struct whose fields are named by their `sms` tag or, by
+// default, their lower-cased name.
+func (r *TemplateRegistry) Render(name, locale string, params interface{}) (string, error) {
+	tpl, _, err := r.Lookup(name, locale)
+	if err != nil {
+		return "", err
+	}
+	fields, err := templateFields(params)
+	if err != nil {
+		return "", err
+	}
+	return tpl.Render(fields)
+}
+
+func templateFields(params interface{}) (map[string]string, error) {
+	switch p := params.(type) {
+	case nil:
+		return nil, nil
+	case map[string]string:
+		return p, nil
+	}
+
+	v := reflect.ValueOf(params)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	fields := map[string]string{}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("template params: unsupported map key type %s", v.Type().Key())
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			fields[iter.Key().String()] = fmt.Sprint(iter.Value().Interface())
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := f.Tag.Get("sms")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			fields[name] = fmt.Sprint(v.Field(i).Interface())
+		}
+	default:
+		return nil, fmt.Errorf("template params: unsupported type %T", params)
+	}
+	return fields, nil
+}
+
+// TemplateLintError reports a translation of a template whose placeholders
+// differ from the fallback locale, or a missing translation.
+type TemplateLintError struct {
+	Name    string
+	Locale  string
+	Missing []string // placeholders of the reference locale not used
+	Extra   []string // placeholders unknown to the reference locale
+}
+
+func (e *TemplateLintError) Error() string {
+	if e.Missing == nil && e.Extra == nil {
+		return fmt.Sprintf("template %s is not translated in %s", e.Name, e.Locale)
+	}
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Extra) > 0 {
+		parts = append(parts, "unexpected "+strings.Join(e.Extra, ", "))
+	}
+	return fmt.Sprintf("template %s (%s): %s", e.Name, e.Locale, strings.Join(parts, "; "))
+}
+
+// Lint checks that every template is available in every locale of the
+// registry, with the same placeholders as in the fallback locale (or the
+// first locale in alphabetical order when it has no fallback translation).
+func (r *TemplateRegistry) Lint() []*TemplateLintError {
+	allLocales := map[string]bool{}
+	for _, locales := range r.templates {
+		for l := range locales {
+			allLocales[l] = true
+		}
+	}
+	locales := make([]string, 0, len(allLocales))
+	for l := range allLocales {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+
+	var errs []*TemplateLintError
+	for _, name := range r.Names() {
+		tpls := r.templates[name]
+		ref, ok := tpls[r.fallback]
+		if !ok {
+			for _, l := range locales {
+				if ref, ok = tpls[l]; ok {
+					break
+				}
+			}
+		}
+		refVars := ref.Variables()
+
+		for _, l := range locales {
+			tpl, ok := tpls[l]
+			if !ok {
+				errs = append(errs, &TemplateLintError{Name: name, Locale: l})
+				continue
+			}
+			vars := tpl.Variables()
+			missing, extra := diffSorted(refVars, vars), diffSorted(vars, refVars)
+			if len(missing) > 0 || len(extra) > 0 {
+				errs = append(errs, &TemplateLintError{Name: name, Locale: l, Missing: missing, Extra: extra})
+			}
+		}
+	}
+	return errs
+}
+
+// diffSorted returns the elements of a that are not in b.
+func diffSorted(a, b []string) []string {
+	var diff []string
+	for _, s := range a {
+		i := sort.SearchStrings(b, s)
+		if i == len(b) || b[i] != s {
+			diff = append(diff, s)
+		}
+	}
+	return diff
+}
+
+// Names returns the sorted names of the templates of the registry.
+func (r *TemplateRegistry) Names() []string {
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SendTemplate renders the template name in locale with params, see
+// TemplateRegistry.Render, and sends it to phoneNumber.
+func (c *Client) SendTemplate(ctx context.Context, name, locale, phoneNumber string, params interface{}) (*SMSResponse, error) {
+	if c.templates == nil {
+		return nil, ErrNoTemplates
+	}
+	msg, err := c.templates.Render(name, locale, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.sendSMS(ctx, &SMS{PhoneNumbers: phoneNumber, Message: msg})
+}
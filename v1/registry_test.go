@@ -0,0 +1,143 @@
+package smspartner_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+var testTemplates = fstest.MapFS{
+	"fr/otp.txt":              {Data: []byte("Votre code est {{code}}.\n")},
+	"en/otp.txt":              {Data: []byte("Your code is {{code}}.\n")},
+	"fr/delivery_notice.txt":  {Data: []byte("Bonjour {{firstname}}, votre colis arrive le {{date}}.")},
+	"en/delivery_notice.txt":  {Data: []byte("Hello {{firstname}}, your parcel arrives on {{day}}.")},
+	"fr/appointment.txt":      {Data: []byte("Rendez-vous le {{date}}.")},
+	"fr/README.md":            {Data: []byte("not a template")},
+	"en-gb/delivery_date.txt": {Data: []byte("{{date}}")},
+}
+
+func TestTemplateRegistryLookup(t *testing.T) {
+	reg, err := smspartner.LoadTemplates(testTemplates, "fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := [...]struct {
+		name, locale string
+		wantLocale   string
+		wantErr      bool
+	}{
+		{"otp", "en", "en", false},
+		{"otp", "EN_us", "en", false},
+		{"otp", "de", "fr", false},
+		{"appointment", "en", "fr", false},
+		{"delivery_date", "fr", "", true},
+		{"unknown", "fr", "", true},
+	}
+
+	for i, tt := range tests {
+		_, locale, err := reg.Lookup(tt.name, tt.locale)
+		if tt.wantErr != (err != nil) {
+			t.Errorf("#%d. got error: %v", i, err)
+		}
+		if locale != tt.wantLocale {
+			t.Errorf("#%d. got: %q, want: %q", i, locale, tt.wantLocale)
+		}
+	}
+}
+
+func TestTemplateRegistryRender(t *testing.T) {
+	reg, err := smspartner.LoadTemplates(testTemplates, "fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type notice struct {
+		FirstName string
+		Date      string `sms:"date"`
+		internal  int
+	}
+
+	tests := [...]struct {
+		params interface{}
+		want   string
+	}{
+		{map[string]string{"firstname": "Léa", "date": "12/05"}, "Bonjour Léa, votre colis arrive le 12/05."},
+		{map[string]interface{}{"firstname": "Léa", "date": 12}, "Bonjour Léa, votre colis arrive le 12."},
+		{&notice{FirstName: "Hugo", Date: "lundi"}, "Bonjour Hugo, votre colis arrive le lundi."},
+	}
+
+	for i, tt := range tests {
+		got, err := reg.Render("delivery_notice", "fr", tt.params)
+		if err != nil {
+			t.Fatalf("#%d. %v", i, err)
+		}
+		if got != tt.want {
+			t.Errorf("#%d. got: %q, want: %q", i, got, tt.want)
+		}
+	}
+
+	if _, err := reg.Render("delivery_notice", "fr", map[string]string{"firstname": "Léa"}); err == nil {
+		t.Error("expected a missing variable error")
+	}
+}
+
+func TestTemplateRegistryLint(t *testing.T) {
+	reg, err := smspartner.LoadTemplates(testTemplates, "fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range reg.Lint() {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		"template appointment is not translated in en",
+		"template appointment is not translated in en-gb",
+		"template delivery_date is not translated in en",
+		"template delivery_date is not translated in fr",
+		"template delivery_notice (en): missing date; unexpected day",
+		"template delivery_notice is not translated in en-gb",
+		"template otp is not translated in en-gb",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestSendTemplate(t *testing.T) {
+	var got smspartner.SMS
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		b, err := fixture("send_sms.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+
+	reg, err := smspartner.LoadTemplates(testTemplates, "fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, teardown := testingHTTPClient(t, h, smspartner.Templates(reg))
+	defer teardown()
+
+	_, err = cli.SendTemplate(context.Background(), "otp", "en-US", "0620123456", map[string]string{"code": "4821"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PhoneNumbers != "0620123456" || got.Message != "Your code is 4821." {
+		t.Errorf("unexpected SMS: %#v", got)
+	}
+}
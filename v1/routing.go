@@ -0,0 +1,195 @@
+package smspartner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MessageKind is the purpose of a message, matched by the rules of a Router.
+type MessageKind string
+
+// List of values that MessageKind can take.
+const (
+	KindOTP           MessageKind = "otp"
+	KindTransactional MessageKind = "transactional"
+	KindMarketing     MessageKind = "marketing"
+)
+
+// RoutingRule routes to Gamme the messages of one of Kinds whose recipients
+// are all in one of Countries (e.g. "FR"). Empty Kinds or Countries match
+// any message.
+type RoutingRule struct {
+	Name      string
+	Kinds     []MessageKind
+	Countries []string
+	Gamme     Gamme
+}
+
+func (r *RoutingRule) match(kind MessageKind, countries []string) bool {
+	if len(r.Kinds) > 0 && !containsKind(r.Kinds, kind) {
+		return false
+	}
+	if len(r.Countries) == 0 {
+		return true
+	}
+	for _, country := range countries {
+		if !containsCountry(r.Countries, country) {
+			return false
+		}
+	}
+	return len(countries) > 0
+}
+
+func containsKind(kinds []MessageKind, kind MessageKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCountry(countries []string, country string) bool {
+	for _, c := range countries {
+		if strings.EqualFold(c, country) {
+			return true
+		}
+	}
+	return false
+}
+
+// Route is the gamme chosen for a message by a Router.
+type Route struct {
+	Gamme Gamme
+	Rule  *RoutingRule // nil for the default gamme
+	// Fallback is set when the message was routed to Premium because the
+	// LowCost credits could not pay for it.
+	Fallback bool
+}
+
+func (r *Route) String() string {
+	name := "default"
+	if r.Rule != nil {
+		name = r.Rule.Name
+	}
+	s := fmt.Sprintf("%s (%s)", gammeName(r.Gamme), name)
+	if r.Fallback {
+		s += ", lowcost credits exhausted"
+	}
+	return s
+}
+
+// Router chooses the gamme of the messages sent without one: the gamme of
+// the first rule matching the message, or else Default. See the Routing
+// option.
+type Router struct {
+	Rules   []*RoutingRule
+	Default Gamme  // defaults to Premium
+	Region  string // region of national numbers, defaults to "FR"
+
+	// CheckCredits routes to Premium the messages routed to LowCost when
+	// the LowCost credits returned by CheckCredits are fewer than their
+	// number of SMS.
+	CheckCredits bool
+}
+
+// NewRouter returns a router applying rules, in order.
+func NewRouter(rules ...*RoutingRule) *Router {
+	return &Router{Rules: rules}
+}
+
+func (r *Router) region() string {
+	if r.Region == "" {
+		return "FR"
+	}
+	return r.Region
+}
+
+// Route returns the gamme of a message of kind sent to phoneNumbers.
+func (r *Router) Route(kind MessageKind, phoneNumbers ...string) *Route {
+	var countries []string
+	seen := map[string]bool{}
+	for _, number := range phoneNumbers {
+		country := recipientCountry(number, r.region())
+		if !seen[country] {
+			seen[country] = true
+			countries = append(countries, country)
+		}
+	}
+
+	for _, rule := range r.Rules {
+		if rule.match(kind, countries) {
+			return &Route{Gamme: defaultGamme(rule.Gamme), Rule: rule}
+		}
+	}
+	return &Route{Gamme: defaultGamme(r.Default)}
+}
+
+// route chooses the gamme of a message of kind, made of segments SMS, sent
+// to phoneNumbers. It returns nil if there is no router or gamme is already
+// set by the caller.
+func (c *Client) route(ctx context.Context, gamme Gamme, kind MessageKind, segments int, phoneNumbers []string) (*Route, error) {
+	if c.router == nil || gamme != 0 {
+		return nil, nil
+	}
+	route := c.router.Route(kind, phoneNumbers...)
+	if route.Gamme != LowCost || !c.router.CheckCredits {
+		return route, nil
+	}
+
+	resp, err := c.checkCredits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Credits == nil {
+		return nil, fmt.Errorf("unexpected response: no credits")
+	}
+	if resp.Credits.CreditSmsLowCost < segments {
+		route.Gamme, route.Fallback = Premium, true
+	}
+	return route, nil
+}
+
+// routeSMS returns the route of sms chosen by the router of c, if any. The
+// gamme of sms is left unchanged, so that it is routed again when it is
+// sent again.
+func (c *Client) routeSMS(ctx context.Context, sms *SMS) (*Route, error) {
+	numbers := splitPhoneNumbers(sms.PhoneNumbers)
+	segments := len(numbers) * CountSegments(sms.Message).Segments
+	return c.route(ctx, sms.Gamme, sms.Kind, segments, numbers)
+}
+
+// routeBulkSMS returns the route of bulksms chosen by the router of c, if
+// any. The gamme of bulksms is left unchanged.
+func (c *Client) routeBulkSMS(ctx context.Context, bulksms *BulkSMS) (*Route, error) {
+	numbers := make([]string, 0, len(bulksms.SMSList))
+	segments := 0
+	for _, sms := range bulksms.SMSList {
+		if sms == nil {
+			continue
+		}
+		numbers = append(numbers, sms.PhoneNumber)
+		segments += CountSegments(sms.Message).Segments
+	}
+	return c.route(ctx, bulksms.Gamme, bulksms.Kind, segments, numbers)
+}
+
+// routedSMS returns a copy of sms sent with the gamme of route, if not nil.
+func routedSMS(sms *SMS, route *Route) *SMS {
+	out := *sms
+	if route != nil {
+		out.Gamme = route.Gamme
+	}
+	return &out
+}
+
+// routedBulkSMS returns a copy of bulksms sent with the gamme of route, if
+// not nil.
+func routedBulkSMS(bulksms *BulkSMS, route *Route) *BulkSMS {
+	out := *bulksms
+	if route != nil {
+		out.Gamme = route.Gamme
+	}
+	return &out
+}
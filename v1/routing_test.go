@@ -0,0 +1,107 @@
+package smspartner_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+// routingHandler serves the LowCost credits and records the gamme of the
+// SMS sent.
+type routingHandler struct {
+	lowCostCredits int
+	gammes         []smspartner.Gamme
+	t              *testing.T
+}
+
+func (h *routingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.URL.Path {
+	case "/v1/me":
+		fmt.Fprintf(w, `{"success": true, "code": 200, "credits": {"creditSmsLowCost": %d, "currency": "EUR"}}`, h.lowCostCredits)
+	case "/v1/send":
+		var sms smspartner.SMS
+		if err := json.NewDecoder(r.Body).Decode(&sms); err != nil {
+			h.t.Fatal(err)
+		}
+		h.gammes = append(h.gammes, sms.Gamme)
+		b, err := fixture("send_sms.json")
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		w.Write(b)
+	default:
+		h.t.Errorf("unexpected request: %s", r.URL.Path)
+	}
+}
+
+func TestRouting(t *testing.T) {
+	router := smspartner.NewRouter(
+		&smspartner.RoutingRule{Name: "always premium", Countries: []string{"BE"}, Gamme: smspartner.Premium},
+		&smspartner.RoutingRule{Name: "otp", Kinds: []smspartner.MessageKind{smspartner.KindOTP, smspartner.KindTransactional}, Gamme: smspartner.Premium},
+		&smspartner.RoutingRule{Name: "marketing FR", Kinds: []smspartner.MessageKind{smspartner.KindMarketing}, Countries: []string{"FR"}, Gamme: smspartner.LowCost},
+	)
+	router.CheckCredits = true
+	h := &routingHandler{lowCostCredits: 10, t: t}
+	cli, teardown := testingHTTPClient(t, h, smspartner.Routing(router))
+	defer teardown()
+
+	for _, tc := range []struct {
+		sms      *smspartner.SMS
+		want     smspartner.Gamme
+		rule     string
+		fallback bool
+	}{
+		{&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Sale", Kind: smspartner.KindMarketing}, smspartner.LowCost, "marketing FR", false},
+		{&smspartner.SMS{PhoneNumbers: "+32470123456", Message: "Sale", Kind: smspartner.KindMarketing}, smspartner.Premium, "always premium", false},
+		{&smspartner.SMS{PhoneNumbers: "0620123456", Message: "1234", Kind: smspartner.KindOTP}, smspartner.Premium, "otp", false},
+		{&smspartner.SMS{PhoneNumbers: "0620123456,+447700900123", Message: "Sale", Kind: smspartner.KindMarketing}, smspartner.Premium, "", false},
+		{&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Sale", Gamme: smspartner.Premium, Kind: smspartner.KindMarketing}, smspartner.Premium, "", false},
+	} {
+		resp, err := cli.SendSMS(tc.sms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := h.gammes[len(h.gammes)-1]; got != tc.want {
+			t.Errorf("%s: got gamme: %d, want: %d", tc.sms.PhoneNumbers, got, tc.want)
+		}
+		var rule string
+		if resp.Route != nil && resp.Route.Rule != nil {
+			rule = resp.Route.Rule.Name
+		}
+		if rule != tc.rule {
+			t.Errorf("%s: got rule: %q, want: %q", tc.sms.PhoneNumbers, rule, tc.rule)
+		}
+	}
+
+	// 11 recipients of a marketing SMS, for 10 LowCost credits
+	var numbers []string
+	for i := 0; i < 11; i++ {
+		numbers = append(numbers, fmt.Sprintf("06201234%02d", i))
+	}
+	sms := &smspartner.SMS{PhoneNumbers: strings.Join(numbers, ","), Message: "Sale", Kind: smspartner.KindMarketing}
+	resp, err := cli.SendSMS(sms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Route == nil || !resp.Route.Fallback || resp.Route.Gamme != smspartner.Premium {
+		t.Errorf("got route: %v, want a fallback to premium", resp.Route)
+	}
+
+	// the SMS is routed again when it is sent again
+	if sms.Gamme != 0 {
+		t.Errorf("got gamme: %d, want the SMS unchanged", sms.Gamme)
+	}
+	h.lowCostCredits = 100
+	if _, err := cli.SendSMS(sms); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.gammes[len(h.gammes)-1]; got != smspartner.LowCost {
+		t.Errorf("got gamme: %d, want: %d", got, smspartner.LowCost)
+	}
+}
@@ -0,0 +1,403 @@
+package smspartner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoScheduleStore = errors.New("The client has no schedule store, see the Schedules option")
+
+// ScheduledMessage is a send scheduled for later delivery, as recorded by
+// the Schedules option. SMS or BulkSMS holds the request that was sent,
+// without the API key.
+type ScheduledMessage struct {
+	MessageID    int       `json:"messageId"`
+	Tag          string    `json:"tag,omitempty"`
+	Campaign     string    `json:"campaign,omitempty"`
+	PhoneNumbers []string  `json:"phoneNumbers"`
+	DeliveryAt   time.Time `json:"deliveryAt"`
+	CreatedAt    time.Time `json:"createdAt"`
+	SMS          *SMS      `json:"sms,omitempty"`
+	BulkSMS      *BulkSMS  `json:"bulkSms,omitempty"`
+}
+
+// ScheduleStore keeps track of the scheduled messages.
+// Implementations must be safe for concurrent use.
+type ScheduleStore interface {
+	Add(msg *ScheduledMessage) error
+	Remove(messageID int) error
+	List() ([]*ScheduledMessage, error)
+	// Replace removes the message oldID and adds msg in a single update.
+	Replace(oldID int, msg *ScheduledMessage) error
+}
+
+// ScheduleRecordError is returned when a message has been scheduled but
+// could not be recorded in the schedule store. The response is returned
+// along with the error: the message must not be sent again.
+type ScheduleRecordError struct {
+	MessageID int
+	Err       error
+}
+
+func (e *ScheduleRecordError) Error() string {
+	return fmt.Sprintf("message %d has been scheduled but could not be recorded: %v", e.MessageID, e.Err)
+}
+
+func (e *ScheduleRecordError) Unwrap() error {
+	return e.Err
+}
+
+// MemoryScheduleStore is a ScheduleStore kept in memory.
+type MemoryScheduleStore struct {
+	mu       sync.Mutex
+	messages map[int]*ScheduledMessage
+}
+
+// NewMemoryScheduleStore returns an empty in-memory store.
+func NewMemoryScheduleStore() *MemoryScheduleStore {
+	return &MemoryScheduleStore{messages: map[int]*ScheduledMessage{}}
+}
+
+// Add records msg, replacing any message with the same ID.
+func (s *MemoryScheduleStore) Add(msg *ScheduledMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[msg.MessageID] = msg
+	return nil
+}
+
+// Remove forgets a message. Removing an unknown message is not an error.
+func (s *MemoryScheduleStore) Remove(messageID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.messages, messageID)
+	return nil
+}
+
+// List returns the messages ordered by delivery time.
+func (s *MemoryScheduleStore) List() ([]*ScheduledMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortScheduled(s.messages), nil
+}
+
+// Replace removes the message oldID and adds msg.
+func (s *MemoryScheduleStore) Replace(oldID int, msg *ScheduledMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.messages, oldID)
+	s.messages[msg.MessageID] = msg
+	return nil
+}
+
+// FileScheduleStore is a ScheduleStore kept in a JSON file, rewritten
+// atomically on every change. A missing file is an empty store.
+type FileScheduleStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewFileScheduleStore returns a store kept in the file path.
+func NewFileScheduleStore(path string) *FileScheduleStore {
+	return &FileScheduleStore{Path: path}
+}
+
+func (s *FileScheduleStore) load() (map[int]*ScheduledMessage, error) {
+	messages := map[int]*ScheduledMessage{}
+	blob, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return messages, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*ScheduledMessage
+	if err := json.Unmarshal(blob, &list); err != nil {
+		return nil, fmt.Errorf("error reading schedule store %s: %v", s.Path, err)
+	}
+	for _, msg := range list {
+		messages[msg.MessageID] = msg
+	}
+	return messages, nil
+}
+
+// update applies f to the messages of the file and saves them.
+func (s *FileScheduleStore) update(f func(map[int]*ScheduledMessage)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages, err := s.load()
+	if err != nil {
+		return err
+	}
+	f(messages)
+	blob, err := json.MarshalIndent(sortScheduled(messages), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, blob)
+}
+
+// Add records msg, replacing any message with the same ID.
+func (s *FileScheduleStore) Add(msg *ScheduledMessage) error {
+	return s.update(func(messages map[int]*ScheduledMessage) {
+		messages[msg.MessageID] = msg
+	})
+}
+
+// Remove forgets a message. Removing an unknown message is not an error.
+func (s *FileScheduleStore) Remove(messageID int) error {
+	return s.update(func(messages map[int]*ScheduledMessage) {
+		delete(messages, messageID)
+	})
+}
+
+// List returns the messages ordered by delivery time.
+func (s *FileScheduleStore) List() ([]*ScheduledMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return sortScheduled(messages), nil
+}
+
+// Replace removes the message oldID and adds msg.
+func (s *FileScheduleStore) Replace(oldID int, msg *ScheduledMessage) error {
+	return s.update(func(messages map[int]*ScheduledMessage) {
+		delete(messages, oldID)
+		messages[msg.MessageID] = msg
+	})
+}
+
+func sortScheduled(messages map[int]*ScheduledMessage) []*ScheduledMessage {
+	list := make([]*ScheduledMessage, 0, len(messages))
+	for _, msg := range messages {
+		list = append(list, msg)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].DeliveryAt.Equal(list[j].DeliveryAt) {
+			return list[i].DeliveryAt.Before(list[j].DeliveryAt)
+		}
+		return list[i].MessageID < list[j].MessageID
+	})
+	return list
+}
+
+// deliveryTime returns the time of a scheduled delivery, read in the time
+// zone of the API.
+func deliveryTime(scheduled string, hour, minute int) (time.Time, error) {
+	day, err := time.ParseInLocation(layout, scheduled, apiLocation)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute), nil
+}
+
+// scheduledSMS returns the record of sms, sent with the response resp.
+func scheduledSMS(sms *SMS, resp *SMSResponse) (*ScheduledMessage, error) {
+	at, err := deliveryTime(sms.ScheduledDeliveryDate, sms.Time, sms.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	req := *sms
+	req.APIKey, req.Recipients = "", nil
+	var numbers []string
+	for _, p := range strings.Split(sms.PhoneNumbers, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			numbers = append(numbers, p)
+		}
+	}
+	return &ScheduledMessage{
+		MessageID:    resp.MessageID,
+		Tag:          sms.Tag,
+		PhoneNumbers: numbers,
+		DeliveryAt:   at,
+		CreatedAt:    time.Now(),
+		SMS:          &req,
+	}, nil
+}
+
+// scheduledBulkSMS returns the record of bulksms, sent with the response
+// resp by the campaign campaign, if any.
+func scheduledBulkSMS(bulksms *BulkSMS, resp *BulkSMSResponse, campaign string) (*ScheduledMessage, error) {
+	at, err := deliveryTime(bulksms.ScheduledDeliveryDate, bulksms.Time, bulksms.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	req := *bulksms
+	req.APIKey = ""
+	numbers := make([]string, 0, len(bulksms.SMSList))
+	for _, sms := range bulksms.SMSList {
+		if sms != nil {
+			numbers = append(numbers, sms.PhoneNumber)
+		}
+	}
+	return &ScheduledMessage{
+		MessageID:    resp.MessageID,
+		Tag:          bulksms.Tag,
+		Campaign:     campaign,
+		PhoneNumbers: numbers,
+		DeliveryAt:   at,
+		CreatedAt:    time.Now(),
+		BulkSMS:      &req,
+	}, nil
+}
+
+// recordSMS records sms in the schedule store if it is scheduled.
+func (c *Client) recordSMS(sms *SMS, resp *SMSResponse) error {
+	if c.schedules == nil || sms.ScheduledDeliveryDate == "" {
+		return nil
+	}
+	msg, err := scheduledSMS(sms, resp)
+	if err == nil {
+		err = c.schedules.Add(msg)
+	}
+	if err != nil {
+		return &ScheduleRecordError{MessageID: resp.MessageID, Err: err}
+	}
+	return nil
+}
+
+// recordBulkSMS records bulksms in the schedule store if it is scheduled.
+// campaign is the ID of the campaign sending it, if any.
+func (c *Client) recordBulkSMS(bulksms *BulkSMS, resp *BulkSMSResponse, campaign string) error {
+	if c.schedules == nil || bulksms.ScheduledDeliveryDate == "" {
+		return nil
+	}
+	msg, err := scheduledBulkSMS(bulksms, resp, campaign)
+	if err == nil {
+		err = c.schedules.Add(msg)
+	}
+	if err != nil {
+		return &ScheduleRecordError{MessageID: resp.MessageID, Err: err}
+	}
+	return nil
+}
+
+// Upcoming returns the recorded messages that have not been delivered yet,
+// ordered by delivery time. See the Schedules option.
+func (c *Client) Upcoming() ([]*ScheduledMessage, error) {
+	if c.schedules == nil {
+		return nil, ErrNoScheduleStore
+	}
+	list, err := c.schedules.List()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	upcoming := list[:0:0]
+	for _, msg := range list {
+		if msg.DeliveryAt.After(now) {
+			upcoming = append(upcoming, msg)
+		}
+	}
+	return upcoming, nil
+}
+
+// RescheduleError is returned by Reschedule when the message has been
+// cancelled but could not be sent again. Message holds the cancelled
+// message, which is no longer in the schedule store.
+type RescheduleError struct {
+	Message *ScheduledMessage
+	Err     error
+}
+
+func (e *RescheduleError) Error() string {
+	return fmt.Sprintf("message %d has been cancelled but could not be sent again: %v", e.Message.MessageID, e.Err)
+}
+
+func (e *RescheduleError) Unwrap() error {
+	return e.Err
+}
+
+// Reschedule moves a recorded message to at by cancelling it and sending it
+// again, and replaces it in the schedule store by the new message.
+// If the cancellation fails nothing is changed. If the new message could not
+// be sent the error is a *RescheduleError, and if it could not be recorded a
+// *ScheduleRecordError along with the new message.
+func (c *Client) Reschedule(ctx context.Context, messageID int, at time.Time) (*ScheduledMessage, error) {
+	if c.schedules == nil {
+		return nil, ErrNoScheduleStore
+	}
+	list, err := c.schedules.List()
+	if err != nil {
+		return nil, err
+	}
+	var old *ScheduledMessage
+	for _, msg := range list {
+		if msg.MessageID == messageID {
+			old = msg
+		}
+	}
+	if old == nil {
+		return nil, fmt.Errorf("message %d is not in the schedule store", messageID)
+	}
+
+	at = at.In(apiLocation)
+	date, hour, minute := at.Format(layout), at.Hour(), at.Minute()
+	if !c.skipValidation {
+		var errs ValidationErrors
+		validateSending(&errs, 0, "", date, hour, minute)
+		if err := errs.err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := c.cancelMessage(ctx, messageID); err != nil {
+		return nil, err
+	}
+
+	msg, err := c.resend(ctx, old, date, hour, minute)
+	if err != nil {
+		if rerr := c.schedules.Remove(messageID); rerr != nil {
+			err = fmt.Errorf("%v (and removing it from the schedule store: %v)", err, rerr)
+		}
+		return nil, &RescheduleError{Message: old, Err: err}
+	}
+	if err := c.schedules.Replace(messageID, msg); err != nil {
+		return msg, &ScheduleRecordError{MessageID: msg.MessageID, Err: err}
+	}
+	return msg, nil
+}
+
+// resend sends a copy of msg scheduled at the given time and returns its record.
+func (c *Client) resend(ctx context.Context, msg *ScheduledMessage, date string, hour, minute int) (*ScheduledMessage, error) {
+	switch {
+	case msg.SMS != nil:
+		sms := *msg.SMS
+		sms.Tag, sms.ScheduledDeliveryDate, sms.Time, sms.Minute = msg.Tag, date, hour, minute
+		resp, err := c.postSMS(ctx, &sms)
+		if err != nil {
+			return nil, err
+		}
+		return scheduledSMS(&sms, resp)
+
+	case msg.BulkSMS != nil:
+		bulksms := *msg.BulkSMS
+		bulksms.Tag, bulksms.ScheduledDeliveryDate, bulksms.Time, bulksms.Minute = msg.Tag, date, hour, minute
+		if _, err := c.suppressBulkSMS(ctx, &bulksms); err != nil {
+			return nil, err
+		}
+		resp, err := c.sendBulkSMS(ctx, &bulksms)
+		if err != nil {
+			return nil, err
+		}
+		return scheduledBulkSMS(&bulksms, resp, msg.Campaign)
+	}
+	return nil, fmt.Errorf("message %d has no request to send again", msg.MessageID)
+}
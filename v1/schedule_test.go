@@ -0,0 +1,259 @@
+package smspartner_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+// scheduleHandler sends SMS and cancels message 2270142; message 2254444 has
+// already been sent and any other message is unknown.
+func scheduleHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var name string
+		switch r.URL.Path {
+		case "/v1/send":
+			name = "send_sms.json"
+		case "/v1/bulk-send":
+			name = "send_bulksms.json"
+		case "/v1/message-cancel":
+			switch r.URL.Query().Get("messageId") {
+			case "2270142":
+				name = "cancel_sms.json"
+			case "2254444":
+				w.WriteHeader(http.StatusBadRequest)
+				name = "cancel_sms_error.json"
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"success": false, "code": 404, "message": "Message introuvable"}`)
+				return
+			}
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+			return
+		}
+		b, err := fixture(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+}
+
+func TestCancelTag(t *testing.T) {
+	store := smspartner.NewMemoryScheduleStore()
+	cli, teardown := testingHTTPClient(t, scheduleHandler(t), smspartner.Schedules(store))
+	defer teardown()
+
+	date := fmt.Sprintf("01/01/%d", time.Now().Year()+1)
+	if _, err := cli.SendSMS(&smspartner.SMS{
+		PhoneNumbers:          "0620123456",
+		Message:               "Hello",
+		ScheduledDeliveryDate: date,
+		Time:                  9,
+		Minute:                30,
+		Tag:                   "promo",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.SendBulkSMS(&smspartner.BulkSMS{
+		SMSList:               []*smspartner.SMSPayload{{PhoneNumber: "0621123456", Message: "Hello"}},
+		ScheduledDeliveryDate: date,
+		Time:                  9,
+		Minute:                5,
+		Tag:                   "promo",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// messages sent immediately are not recorded
+	if _, err := cli.SendSMS(&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Hello", Tag: "promo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].MessageID != 2254444 || list[1].MessageID != 2270142 {
+		t.Fatalf("unexpected schedule: %s", jsonString(list))
+	}
+	if want := time.Date(time.Now().Year()+1, 1, 1, 9, 30, 0, 0, time.UTC).Add(-time.Hour); !list[1].DeliveryAt.Equal(want) {
+		t.Errorf("got: %v, want: %v", list[1].DeliveryAt, want)
+	}
+
+	results, err := cli.CancelTag(context.Background(), "promo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Outcome != smspartner.AlreadySent || results[1].Outcome != smspartner.Cancelled {
+		t.Errorf("unexpected results: %s", jsonString(results))
+	}
+	if list, _ := store.List(); len(list) != 0 {
+		t.Errorf("unexpected schedule after cancellation: %s", jsonString(list))
+	}
+}
+
+func TestCancelMany(t *testing.T) {
+	cli, teardown := testingHTTPClient(t, scheduleHandler(t))
+	defer teardown()
+
+	results := cli.CancelMany(context.Background(), []int{2270142, 999, 2254444})
+
+	want := []smspartner.CancelOutcome{smspartner.Cancelled, smspartner.UnknownMessage, smspartner.AlreadySent}
+	for i, r := range results {
+		if r.Outcome != want[i] {
+			t.Errorf("message %d: got: %s, want: %s", r.MessageID, r.Outcome, want[i])
+		}
+	}
+	if results[1].Err == nil {
+		t.Error("expected a non-nil error for the unknown message")
+	}
+
+	if _, err := cli.CancelCampaign(context.Background(), "c1"); err != smspartner.ErrNoScheduleStore {
+		t.Errorf("got: %v, want: %v", err, smspartner.ErrNoScheduleStore)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range cli.CancelMany(ctx, []int{2270142}) {
+		if r.Outcome != smspartner.CancelFailed {
+			t.Errorf("got: %s, want: %s", r.Outcome, smspartner.CancelFailed)
+		}
+	}
+}
+
+// rescheduleHandler numbers the messages it sends from 1 and fails to send
+// once failing is set.
+type rescheduleHandler struct {
+	mu      sync.Mutex
+	sent    []smspartner.SMS
+	failing bool
+	t       *testing.T
+}
+
+func (h *rescheduleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.URL.Path {
+	case "/v1/send":
+		if h.failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"success": false, "code": 500, "message": "Erreur interne"}`)
+			return
+		}
+		var sms smspartner.SMS
+		json.NewDecoder(r.Body).Decode(&sms)
+		h.sent = append(h.sent, sms)
+		fmt.Fprintf(w, `{"success": true, "code": 200, "message_id": %d, "nb_sms": 1}`, len(h.sent))
+	case "/v1/message-cancel":
+		b, err := fixture("cancel_sms.json")
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	default:
+		h.t.Errorf("unexpected request: %s", r.URL.Path)
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	store := smspartner.NewFileScheduleStore(filepath.Join(t.TempDir(), "schedule.json"))
+	h := &rescheduleHandler{t: t}
+	cli, teardown := testingHTTPClient(t, h, smspartner.Schedules(store))
+	defer teardown()
+
+	year := time.Now().Year() + 1
+	if _, err := cli.SendSMS(&smspartner.SMS{
+		PhoneNumbers:          "0620123456",
+		Message:               "Hello",
+		ScheduledDeliveryDate: fmt.Sprintf("01/01/%d", year),
+		Time:                  9,
+		Minute:                30,
+		Tag:                   "promo",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// a message delivered in the past is not upcoming
+	if err := store.Add(&smspartner.ScheduledMessage{MessageID: 99, DeliveryAt: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := cli.Reschedule(context.Background(), 1, time.Date(year, 2, 2, 10, 15, 0, 0, paris))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.MessageID != 2 || msg.Tag != "promo" {
+		t.Errorf("unexpected message: %s", jsonString(msg))
+	}
+	if got := h.sent[1]; got.ScheduledDeliveryDate != fmt.Sprintf("02/02/%d", year) || got.Time != 10 || got.Minute != 15 || got.Message != "Hello" {
+		t.Errorf("unexpected SMS: %s", jsonString(got))
+	}
+
+	upcoming, err := cli.Upcoming()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upcoming) != 1 || upcoming[0].MessageID != 2 || upcoming[0].SMS.PhoneNumbers != "0620123456" {
+		t.Errorf("unexpected upcoming messages: %s", jsonString(upcoming))
+	}
+
+	// the message is cancelled but can not be sent again
+	h.failing = true
+	_, err = cli.Reschedule(context.Background(), 2, time.Date(year, 3, 3, 10, 15, 0, 0, paris))
+
+	var rerr *smspartner.RescheduleError
+	if !errors.As(err, &rerr) || rerr.Message.MessageID != 2 {
+		t.Fatalf("got: %v, want a *RescheduleError", err)
+	}
+	if upcoming, _ := cli.Upcoming(); len(upcoming) != 0 {
+		t.Errorf("unexpected upcoming messages: %s", jsonString(upcoming))
+	}
+
+	if _, err := cli.Reschedule(context.Background(), 2, time.Date(year, 3, 3, 10, 15, 0, 0, paris)); err == nil {
+		t.Error("expected a non-nil error for an unknown message")
+	}
+}
+
+func TestRescheduleMidnight(t *testing.T) {
+	store := smspartner.NewFileScheduleStore(filepath.Join(t.TempDir(), "schedule.json"))
+	h := &rescheduleHandler{t: t}
+	cli, teardown := testingHTTPClient(t, h, smspartner.Schedules(store))
+	defer teardown()
+
+	year := time.Now().Year() + 1
+	if _, err := cli.SendSMS(&smspartner.SMS{
+		PhoneNumbers:          "0620123456",
+		Message:               "Hello",
+		ScheduledDeliveryDate: fmt.Sprintf("01/01/%d", year),
+		Time:                  9,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.Reschedule(context.Background(), 1, time.Date(year, 2, 2, 0, 0, 0, 0, paris)); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.sent[1]; got.ScheduledDeliveryDate != fmt.Sprintf("02/02/%d", year) || got.Time != 0 || got.Minute != 0 {
+		t.Errorf("unexpected SMS: %s", jsonString(got))
+	}
+}
@@ -0,0 +1,89 @@
+package smspartner
+
+import "unicode/utf16"
+
+// Encoding is the character set an SMS is sent with.
+type Encoding int
+
+// List of values that Encoding can take.
+const (
+	GSM7 Encoding = iota // GSM 03.38 default alphabet, 7 bits per character
+	UCS2                 // UCS-2, used as soon as a character is not in GSM 03.38
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case GSM7:
+		return "GSM-7"
+	case UCS2:
+		return "UCS-2"
+	}
+	return "unknown"
+}
+
+// Characters per segment for a single SMS and for each part of a
+// concatenated SMS (the user data header takes the difference).
+const (
+	gsm7SingleLen = 160
+	gsm7PartLen   = 153
+	ucs2SingleLen = 70
+	ucs2PartLen   = 67
+)
+
+// gsm7Basic is the GSM 03.38 basic character set.
+var gsm7Basic = map[rune]bool{}
+
+// gsm7Extension characters are sent with an escape and count twice.
+var gsm7Extension = map[rune]bool{
+	'\f': true, '^': true, '{': true, '}': true, '\\': true,
+	'[': true, '~': true, ']': true, '|': true, '€': true,
+}
+
+func init() {
+	const basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+		"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	for _, r := range basic {
+		gsm7Basic[r] = true
+	}
+}
+
+// MessageInfo describes how a message is split into SMS.
+type MessageInfo struct {
+	Encoding Encoding
+	Length   int // in GSM-7 septets or UCS-2 code units
+	Segments int
+}
+
+// CountSegments returns the encoding of msg and the number of SMS needed to send it.
+func CountSegments(msg string) MessageInfo {
+	info := MessageInfo{Encoding: GSM7}
+	for _, r := range msg {
+		switch {
+		case gsm7Basic[r]:
+			info.Length++
+		case gsm7Extension[r]:
+			info.Length += 2
+		default:
+			info.Encoding = UCS2
+		}
+		if info.Encoding == UCS2 {
+			break
+		}
+	}
+
+	single, part := gsm7SingleLen, gsm7PartLen
+	if info.Encoding == UCS2 {
+		info.Length = len(utf16.Encode([]rune(msg)))
+		single, part = ucs2SingleLen, ucs2PartLen
+	}
+
+	switch {
+	case info.Length == 0:
+		info.Segments = 0
+	case info.Length <= single:
+		info.Segments = 1
+	default:
+		info.Segments = (info.Length + part - 1) / part
+	}
+	return info
+}
@@ -2,6 +2,7 @@ package smspartner
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -32,6 +33,13 @@ type SMS struct {
 	Minute                int    `json:"minute,omitempty"`
 	// IsStopSms
 	// Sandbox
+
+	// Recipients, when set, replaces PhoneNumbers.
+	Recipients Recipients `json:"-"`
+	// Tag is a local label of the message, see CancelTag.
+	Tag string `json:"-"`
+	// Kind is the purpose of the message, see the Routing option.
+	Kind MessageKind `json:"-"`
 }
 
 type BulkSMS struct {
@@ -44,17 +52,27 @@ type BulkSMS struct {
 	Minute                int           `json:"minute,omitempty"`
 	// IsStopSms
 	// Sandbox
+
+	// Tag is a local label of the messages, see CancelTag.
+	Tag string `json:"-"`
+	// Kind is the purpose of the messages, see the Routing option.
+	Kind MessageKind `json:"-"`
 }
 
 type SMSResponse struct {
-	Success               bool    `json:"success"`
-	Code                  int     `json:"code"`
-	MessageID             int     `json:"message_id"`
-	NumberOfSMS           int     `json:"nb_sms"`
-	Cost                  float64 `json:"cost"`
-	Currency              string  `json:"currency"`
-	ScheduledDeliveryDate string  `json:"scheduledDeliveryDate"`
-	PhoneNumber           string  `json:"phoneNumber"`
+	Success               bool   `json:"success"`
+	Code                  int    `json:"code"`
+	MessageID             int    `json:"message_id"`
+	NumberOfSMS           int    `json:"nb_sms"`
+	Cost                  Money  `json:"cost"`
+	Currency              string `json:"currency"`
+	ScheduledDeliveryDate string `json:"scheduledDeliveryDate"`
+	PhoneNumber           string `json:"phoneNumber"`
+
+	// Excluded lists the recipients removed before sending, see Suppress.
+	Excluded []*ExcludedRecipient `json:"-"`
+	// Route is the gamme chosen by the Routing option, if any.
+	Route *Route `json:"-"`
 }
 
 type BulkSMSResponse struct {
@@ -62,65 +80,222 @@ type BulkSMSResponse struct {
 	Code            int            `json:"code"`
 	MessageID       int            `json:"message_id"`
 	Currency        string         `json:"currency"`
-	Cost            float64        `json:"cost"`
+	Cost            Money          `json:"cost"`
 	NumberOfSMS     int            `json:"nbSMS"`
 	SMSResponseList []*SMSResponse `json:"SMSResponse_List"`
+
+	// Excluded lists the recipients removed before sending, see Suppress.
+	Excluded []*ExcludedRecipient `json:"-"`
+	// Route is the gamme chosen by the Routing option, if any.
+	Route *Route `json:"-"`
+}
+
+func (r *SMSResponse) UnmarshalJSON(b []byte) error {
+	type response SMSResponse
+	if err := json.Unmarshal(b, (*response)(r)); err != nil {
+		return err
+	}
+	r.Cost.Currency = r.Currency
+	return nil
+}
+
+// MarshalJSON sends the hour and minute of a scheduled SMS even when they are
+// zero, as the API requires them with the date.
+func (sms SMS) MarshalJSON() ([]byte, error) {
+	type request SMS
+	return json.Marshal(struct {
+		request
+		Time   *int `json:"time,omitempty"`
+		Minute *int `json:"minute,omitempty"`
+	}{request(sms), scheduledAt(sms.ScheduledDeliveryDate, sms.Time), scheduledAt(sms.ScheduledDeliveryDate, sms.Minute)})
+}
+
+// MarshalJSON sends the hour and minute of a scheduled bulk SMS, see
+// SMS.MarshalJSON.
+func (bulksms BulkSMS) MarshalJSON() ([]byte, error) {
+	type request BulkSMS
+	return json.Marshal(struct {
+		request
+		Time   *int `json:"time,omitempty"`
+		Minute *int `json:"minute,omitempty"`
+	}{request(bulksms), scheduledAt(bulksms.ScheduledDeliveryDate, bulksms.Time), scheduledAt(bulksms.ScheduledDeliveryDate, bulksms.Minute)})
+}
+
+// scheduledAt returns the hour or minute n to send, or nil to omit it when
+// it is zero and no date is set.
+func scheduledAt(date string, n int) *int {
+	if date == "" && n == 0 {
+		return nil
+	}
+	return &n
+}
+
+func (r *BulkSMSResponse) UnmarshalJSON(b []byte) error {
+	type response BulkSMSResponse
+	if err := json.Unmarshal(b, (*response)(r)); err != nil {
+		return err
+	}
+	r.Cost.Currency = r.Currency
+	for _, smsr := range r.SMSResponseList {
+		if smsr != nil && smsr.Currency == "" {
+			smsr.Cost.Currency = r.Currency
+		}
+	}
+	return nil
 }
 
 // SendSMS sends SMS, either immediately or at a set time.
+// The SMS is validated first, unless the client has the SkipValidation option.
 func (c *Client) SendSMS(sms *SMS) (*SMSResponse, error) {
+	return c.sendSMS(context.Background(), sms)
+}
+
+func (c *Client) sendSMS(ctx context.Context, sms *SMS) (*SMSResponse, error) {
+	resp, err := c.postSMS(ctx, sms)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.recordSMS(sms, resp); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+func (c *Client) postSMS(ctx context.Context, sms *SMS) (*SMSResponse, error) {
+	if sms.Recipients != nil {
+		sms.PhoneNumbers = sms.Recipients.String()
+	}
+	if !c.skipValidation {
+		if err := sms.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	excluded, err := c.suppressSMS(ctx, sms)
+	if err != nil {
+		return nil, err
+	}
+	route, err := c.routeSMS(ctx, sms)
+	if err != nil {
+		return nil, err
+	}
 	sms.APIKey = c.apiKey
-	blob, err := json.Marshal(sms)
+	out := routedSMS(sms, route)
+	blob, err := json.Marshal(out)
 	if err != nil {
 		return nil, err
 	}
 
 	fullURL := fmt.Sprintf("%s/send", c.basePath)
-	req, err := http.NewRequest("POST", fullURL, bytes.NewReader(blob))
+	req, err := http.NewRequestWithContext(ctx, "POST", fullURL, bytes.NewReader(blob))
 	if err != nil {
 		return nil, err
 	}
 
+	done, err := c.guard(ctx, func(p Pricer) (Money, error) { return EstimateSMS(p, out) })
+	if err != nil {
+		return nil, err
+	}
 	blob, err = c.doRequest(req)
 	if err != nil {
+		done(nil)
 		return nil, err
 	}
 
 	smsr := new(SMSResponse)
 	if err := json.Unmarshal(blob, &smsr); err != nil {
+		done(nil)
 		return nil, err
 	}
+	done(smsLedgerEntry(out, smsr))
+	smsr.Excluded, smsr.Route = excluded, route
 	return smsr, nil
 }
 
 // SendBulkSMS sends SMS in batch of 500 either immediately or at a set time.
+// Larger lists are rejected with ErrBulkSMSLimit, see SendBulkSMSBatches.
+// The SMS are validated first, unless the client has the SkipValidation option.
 func (c *Client) SendBulkSMS(bulksms *BulkSMS) (*BulkSMSResponse, error) {
+	ctx := context.Background()
+	if !c.skipValidation {
+		if err := bulksms.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	excluded, err := c.suppressBulkSMS(ctx, bulksms)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.sendBulkSMS(ctx, bulksms)
+	if err != nil {
+		return nil, err
+	}
+	resp.Excluded = excluded
+	if err := c.recordBulkSMS(bulksms, resp, ""); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+func (c *Client) sendBulkSMS(ctx context.Context, bulksms *BulkSMS) (*BulkSMSResponse, error) {
+	return c.postBulkSMS(ctx, bulksms, nil)
+}
+
+// postBulkSMS sends bulksms and calls done with its ledger entry, or reserves
+// its cost with guard if done is nil.
+func (c *Client) postBulkSMS(ctx context.Context, bulksms *BulkSMS, done func(*LedgerEntry)) (*BulkSMSResponse, error) {
+	if len(bulksms.SMSList) > MaxBulkSMS {
+		return nil, ErrBulkSMSLimit
+	}
+	if !c.skipValidation {
+		if err := bulksms.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	route, err := c.routeBulkSMS(ctx, bulksms)
+	if err != nil {
+		return nil, err
+	}
 	bulksms.APIKey = c.apiKey
-	blob, err := json.Marshal(bulksms)
+	out := routedBulkSMS(bulksms, route)
+	blob, err := json.Marshal(out)
 	if err != nil {
 		return nil, err
 	}
 
 	fullURL := fmt.Sprintf("%s/bulk-send", c.basePath)
-	req, err := http.NewRequest("POST", fullURL, bytes.NewReader(blob))
+	req, err := http.NewRequestWithContext(ctx, "POST", fullURL, bytes.NewReader(blob))
 	if err != nil {
 		return nil, err
 	}
 
+	if done == nil {
+		if done, err = c.guard(ctx, func(p Pricer) (Money, error) { return EstimateBulkSMS(p, out) }); err != nil {
+			return nil, err
+		}
+	}
 	blob, err = c.doRequest(req)
 	if err != nil {
+		done(nil)
 		return nil, err
 	}
 
 	bulksmsr := new(BulkSMSResponse)
 	if err := json.Unmarshal(blob, bulksmsr); err != nil {
+		done(nil)
 		return nil, err
 	}
+	done(bulkSMSLedgerEntry(out, bulksmsr))
+	bulksmsr.Route = route
 	return bulksmsr, nil
 }
 
 // SendVirtualNumber sends SMS, either immediately or at a set time, with a long number.
 func (c *Client) SendVirtualNumber(vn *VNumber) (*SMSResponse, error) {
+	if !c.skipValidation {
+		if err := vn.Validate(); err != nil {
+			return nil, err
+		}
+	}
 	vn.APIKey = c.apiKey
 	blob, err := json.Marshal(vn)
 	if err != nil {
@@ -133,15 +308,31 @@ func (c *Client) SendVirtualNumber(vn *VNumber) (*SMSResponse, error) {
 		return nil, err
 	}
 
+	done, err := c.guard(context.Background(), func(p Pricer) (Money, error) {
+		return EstimateSMS(p, &SMS{PhoneNumbers: vn.To, Message: vn.Message})
+	})
+	if err != nil {
+		return nil, err
+	}
 	blob, err = c.doRequest(req)
 	if err != nil {
+		done(nil)
 		return nil, err
 	}
 
 	vnr := new(SMSResponse)
 	if err := json.Unmarshal(blob, &vnr); err != nil {
+		done(nil)
 		return nil, err
 	}
+	done(&LedgerEntry{
+		Endpoint:   "vn/send",
+		MessageID:  vnr.MessageID,
+		Recipients: []string{vn.To},
+		Segments:   vnr.NumberOfSMS,
+		Cost:       vnr.Cost,
+		Countries:  splitCost([]string{vn.To}, vnr.NumberOfSMS, vnr.Cost),
+	})
 	return vnr, nil
 }
 
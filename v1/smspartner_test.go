@@ -2,6 +2,8 @@ package smspartner_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -10,6 +12,7 @@ import (
 	"os"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/hoflish/smspartner-go/v1"
 )
@@ -54,7 +57,7 @@ func TestSendSMS(t *testing.T) {
 	cli, teardown := testingHTTPClient(t, h)
 	defer teardown()
 
-	d := smspartner.NewDate(2018, 8, 16, 17, 45)
+	d := smspartner.NewDate(time.Now().Year()+1, 8, 16, 17, 45)
 	minute, err := d.MinuteToSendSMS()
 	if err != nil {
 		t.Error(err)
@@ -97,7 +100,7 @@ func TestSendSMSWithError(t *testing.T) {
 		fmt.Fprint(w, string(b))
 	})
 
-	cli, teardown := testingHTTPClient(t, h)
+	cli, teardown := testingHTTPClient(t, h, smspartner.SkipValidation())
 	defer teardown()
 
 	sms := &smspartner.SMS{}
@@ -127,7 +130,7 @@ func TestSendBulkSMS(t *testing.T) {
 	cli, teardown := testingHTTPClient(t, h)
 	defer teardown()
 
-	d := smspartner.NewDate(2018, 8, 16, 18, 30)
+	d := smspartner.NewDate(time.Now().Year()+1, 8, 16, 18, 30)
 	minute, err := d.MinuteToSendSMS()
 	if err != nil {
 		t.Error(err)
@@ -154,13 +157,15 @@ func TestSendBulkSMS(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	var totalCost float64
+	var totalCost smspartner.Money
 	for _, smsrl := range res.SMSResponseList {
-		totalCost += smsrl.Cost
+		if totalCost, err = totalCost.Add(smsrl.Cost); err != nil {
+			t.Fatal(err)
+		}
 	}
 
 	if res.Cost != totalCost {
-		t.Errorf("got: %f, want: %f", res.Cost, totalCost)
+		t.Errorf("got: %s, want: %s", res.Cost, totalCost)
 	}
 }
 
@@ -192,10 +197,10 @@ func TestVerifyNumber(t *testing.T) {
 	}
 
 	gotCost := res.Cost
-	wantCost := 0.005
+	wantCost := smspartner.MustParseMoney("0.005", "EUR")
 
 	if gotCost != wantCost {
-		t.Errorf("got: %f, want: %f", gotCost, wantCost)
+		t.Errorf("got: %s, want: %s", gotCost, wantCost)
 	}
 }
 
@@ -301,19 +306,167 @@ func TestGetBulkSMSStatus(t *testing.T) {
 	}
 }
 
+func TestCancelMessage(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		b, err := fixture("cancel_sms.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	res, err := cli.CancelMessage(2271595)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantMessage := "L'envoi du SMS a été annulé."
+	if res.Message != wantMessage {
+		t.Errorf("got: %s, want: %s", res.Message, wantMessage)
+	}
+}
+
+func TestCancelMessageWithError(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		b, err := fixture("cancel_sms_error.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	res, err := cli.CancelMessage(2271595)
+	if res != nil {
+		t.Errorf("response should be nil, but got: %#v", res)
+	}
+
+	var remAPIErr *smspartner.RemoteAPIError
+	if !errors.As(err, &remAPIErr) {
+		t.Fatalf("got: %v, want a *RemoteAPIError", err)
+	}
+	if remAPIErr.Code != 10 {
+		t.Errorf("got: %d, want: %d", remAPIErr.Code, 10)
+	}
+}
+
 func TestListStops(t *testing.T) {
-	t.Error("Not implemented yet")
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		b, err := fixture("stop_list.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	res, err := cli.ListStops()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.NbOfData != len(res.Data) {
+		t.Errorf("got: %d, want: %d", len(res.Data), res.NbOfData)
+	}
+
+	gotPhoneNumber := res.Data[0].PhoneNumber
+	wantPhoneNumber := "+33621123456"
+	if gotPhoneNumber != wantPhoneNumber {
+		t.Errorf("got: %s, want: %s", gotPhoneNumber, wantPhoneNumber)
+	}
 }
 
 func TestAddToStops(t *testing.T) {
-	t.Error("Not implemented yet")
+	var gotPayload map[string]string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&gotPayload); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		b, err := fixture("stop_add.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	res, err := cli.AddStop("+33621123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotPayload["phoneNumber"] != "+33621123456" {
+		t.Errorf("got: %s, want: %s", gotPayload["phoneNumber"], "+33621123456")
+	}
+	if !res.Success || res.Code != 200 {
+		t.Errorf("unexpected response: %#v", res)
+	}
+
+	m, err := cli.AddToStops("+33621123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["message"] != res.Message {
+		t.Errorf("got: %s, want: %s", m["message"], res.Message)
+	}
 }
 
 func TestDeleteFromStops(t *testing.T) {
-	t.Error("Not implemented yet")
+	var gotID string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = r.URL.Query().Get("id")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		b, err := fixture("stop_delete.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	res, err := cli.DeleteStop(1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotID != "1234" {
+		t.Errorf("got: %s, want: %s", gotID, "1234")
+	}
+	wantMessage := "Le numéro a été supprimé de la liste des STOP SMS."
+	if res.Message != wantMessage {
+		t.Errorf("got: %s, want: %s", res.Message, wantMessage)
+	}
+
+	m, err := cli.DeleteFromStops(1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["success"] != true {
+		t.Errorf("got: %v, want: %v", m["success"], true)
+	}
 }
 
-func testingHTTPClient(t *testing.T, handler http.Handler) (*smspartner.Client, func()) {
+func testingHTTPClient(t *testing.T, handler http.Handler, opts ...smspartner.Option) (*smspartner.Client, func()) {
 	server := httptest.NewServer(handler)
 
 	cli := &http.Client{
@@ -326,7 +479,7 @@ func testingHTTPClient(t *testing.T, handler http.Handler) (*smspartner.Client,
 
 	testApiKey := smspartner.APIKey("TEST_API_KEY")
 
-	spClient, err := smspartner.NewClient(cli, testApiKey)
+	spClient, err := smspartner.NewClient(cli, append([]smspartner.Option{testApiKey}, opts...)...)
 	if err != nil {
 		t.Fatalf("error creating client: %v", err)
 	}
@@ -8,18 +8,27 @@ import (
 )
 
 type SMSStatusResp struct {
-	Success     bool    `json:"success,omitempty"`
-	Code        int     `json:"code,omitempty"`
-	Number      string  `json:"number,omitempty"`
-	MessageID   string  `json:"messageId,omitempty"`
-	StopSMS     bool    `json:"stopSms,omitempty"`
-	Date        string  `json:"date,omitempty"`
-	Status      string  `json:"statut,omitempty"`
-	Cost        float64 `json:"cost,omitempty"`
-	CountryCode string  `json:"countryCode,omitempty"`
-	Currency    string  `json:"currency,omitempty"`
-	IsSpam      string  `json:"isSpam,omitempty"`
-	PhoneNumber string  `json:"phoneNumber,omitempty"`
+	Success     bool   `json:"success,omitempty"`
+	Code        int    `json:"code,omitempty"`
+	Number      string `json:"number,omitempty"`
+	MessageID   string `json:"messageId,omitempty"`
+	StopSMS     bool   `json:"stopSms,omitempty"`
+	Date        string `json:"date,omitempty"`
+	Status      string `json:"statut,omitempty"`
+	Cost        Money  `json:"cost"`
+	CountryCode string `json:"countryCode,omitempty"`
+	Currency    string `json:"currency,omitempty"`
+	IsSpam      string `json:"isSpam,omitempty"`
+	PhoneNumber string `json:"phoneNumber,omitempty"`
+}
+
+func (r *SMSStatusResp) UnmarshalJSON(b []byte) error {
+	type response SMSStatusResp
+	if err := json.Unmarshal(b, (*response)(r)); err != nil {
+		return err
+	}
+	r.Cost.Currency = r.Currency
+	return nil
 }
 
 type MultiSMSStatusPayload struct {
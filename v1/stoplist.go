@@ -0,0 +1,287 @@
+package smspartner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultStopListMaxAge is how long the stop list is used before being
+// fetched again when StopList.MaxAge is zero.
+const defaultStopListMaxAge = time.Hour
+
+// ExclusionReason tells why a recipient has been removed before sending.
+type ExclusionReason string
+
+// List of values that ExclusionReason can take.
+const (
+	ExcludedStop       ExclusionReason = "stop"       // the number sent a STOP
+	ExcludedInvalid    ExclusionReason = "invalid"    // the number is not valid
+	ExcludedNumberType ExclusionReason = "numberType" // e.g. a fixed line, see NumberFilter
+)
+
+// ExcludedRecipient is a recipient removed from an SMS before sending.
+type ExcludedRecipient struct {
+	PhoneNumber string // as written in the SMS
+	Reason      ExclusionReason
+	Detail      string
+}
+
+// AllRecipientsExcludedError is returned when no recipient is left to send to.
+type AllRecipientsExcludedError struct {
+	Excluded []*ExcludedRecipient
+}
+
+func (e *AllRecipientsExcludedError) Error() string {
+	return fmt.Sprintf("all %d recipients have been excluded", len(e.Excluded))
+}
+
+// StopList keeps a cached copy of the numbers that sent a STOP, refreshed
+// from AllStops once older than MaxAge, and removes them from outgoing SMS.
+// Set File to keep the copy across restarts. See the Suppress option.
+type StopList struct {
+	Region string        // region of national numbers, defaults to "FR"
+	MaxAge time.Duration // defaults to one hour
+	File   string
+
+	client    *Client
+	mu        sync.RWMutex
+	numbers   map[string]string // E.164 -> date of the STOP
+	updatedAt time.Time
+}
+
+type stopListFile struct {
+	UpdatedAt time.Time         `json:"updatedAt"`
+	Numbers   map[string]string `json:"numbers"`
+}
+
+// NewStopList returns a stop list kept up to date with c, cached in file
+// if it is not empty.
+func (c *Client) NewStopList(file string) *StopList {
+	return &StopList{File: file, client: c}
+}
+
+func (s *StopList) region() string {
+	if s.Region == "" {
+		return "FR"
+	}
+	return s.Region
+}
+
+func (s *StopList) maxAge() time.Duration {
+	if s.MaxAge <= 0 {
+		return defaultStopListMaxAge
+	}
+	return s.MaxAge
+}
+
+// Load reads the cache file. A missing file is not an error.
+func (s *StopList) Load() error {
+	if s.File == "" {
+		return nil
+	}
+	blob, err := os.ReadFile(s.File)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var f stopListFile
+	if err := json.Unmarshal(blob, &f); err != nil {
+		return fmt.Errorf("error reading stop list %s: %v", s.File, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.numbers, s.updatedAt = f.Numbers, f.UpdatedAt
+	return nil
+}
+
+// Refresh fetches the stop list and saves it in the cache file.
+func (s *StopList) Refresh(ctx context.Context) error {
+	numbers := map[string]string{}
+	for item, err := range s.client.AllStops(ctx) {
+		if err != nil {
+			return err
+		}
+		number, err := normalizePhoneNumber(item.PhoneNumber, s.region())
+		if err != nil {
+			number = strings.TrimSpace(item.PhoneNumber)
+		}
+		numbers[number] = item.CreatedAt
+	}
+
+	s.mu.Lock()
+	s.numbers, s.updatedAt = numbers, time.Now()
+	f := stopListFile{UpdatedAt: s.updatedAt, Numbers: numbers}
+	s.mu.Unlock()
+
+	if s.File == "" {
+		return nil
+	}
+	blob, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.File, blob)
+}
+
+// ensureFresh loads or refreshes the list when needed. A failed refresh is
+// only an error when there is no copy of the list to fall back on.
+func (s *StopList) ensureFresh(ctx context.Context) error {
+	s.mu.RLock()
+	loaded, updatedAt := s.numbers != nil, s.updatedAt
+	s.mu.RUnlock()
+
+	if !loaded {
+		if err := s.Load(); err != nil {
+			return err
+		}
+		s.mu.RLock()
+		loaded, updatedAt = s.numbers != nil, s.updatedAt
+		s.mu.RUnlock()
+	}
+	if loaded && time.Since(updatedAt) < s.maxAge() {
+		return nil
+	}
+	if err := s.Refresh(ctx); err != nil && !loaded {
+		return err
+	}
+	return nil
+}
+
+// Contains reports whether phoneNumber is in the cached stop list.
+func (s *StopList) Contains(phoneNumber string) bool {
+	_, ok := s.lookup(phoneNumber)
+	return ok
+}
+
+func (s *StopList) lookup(phoneNumber string) (string, bool) {
+	number, err := normalizePhoneNumber(phoneNumber, s.region())
+	if err != nil {
+		number = strings.TrimSpace(phoneNumber)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	date, ok := s.numbers[number]
+	return date, ok
+}
+
+func (s *StopList) exclusion(phoneNumber string) (*ExcludedRecipient, bool) {
+	date, ok := s.lookup(phoneNumber)
+	if !ok {
+		return nil, false
+	}
+	detail := "STOP received"
+	if date != "" {
+		detail += " on " + date
+	}
+	return &ExcludedRecipient{PhoneNumber: phoneNumber, Reason: ExcludedStop, Detail: detail}, true
+}
+
+// FilterSMS removes the stopped numbers from sms and returns them.
+func (s *StopList) FilterSMS(ctx context.Context, sms *SMS) ([]*ExcludedRecipient, error) {
+	if err := s.ensureFresh(ctx); err != nil {
+		return nil, err
+	}
+
+	var excluded []*ExcludedRecipient
+	if sms.Recipients != nil {
+		var kept Recipients
+		for _, rcpt := range sms.Recipients {
+			if ex, ok := s.exclusion(rcpt.PhoneNumber); ok {
+				excluded = append(excluded, ex)
+			} else {
+				kept = append(kept, rcpt)
+			}
+		}
+		sms.Recipients = kept
+		sms.PhoneNumbers = kept.String()
+		return excluded, nil
+	}
+
+	var kept []string
+	for _, p := range strings.Split(sms.PhoneNumbers, ",") {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		if ex, ok := s.exclusion(strings.TrimSpace(p)); ok {
+			excluded = append(excluded, ex)
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	sms.PhoneNumbers = strings.Join(kept, ",")
+	return excluded, nil
+}
+
+// FilterBulkSMS removes the SMS sent to stopped numbers from bulksms and
+// returns their recipients.
+func (s *StopList) FilterBulkSMS(ctx context.Context, bulksms *BulkSMS) ([]*ExcludedRecipient, error) {
+	if err := s.ensureFresh(ctx); err != nil {
+		return nil, err
+	}
+
+	var excluded []*ExcludedRecipient
+	kept := make([]*SMSPayload, 0, len(bulksms.SMSList))
+	for _, sms := range bulksms.SMSList {
+		if sms == nil {
+			continue
+		}
+		if ex, ok := s.exclusion(sms.PhoneNumber); ok {
+			excluded = append(excluded, ex)
+		} else {
+			kept = append(kept, sms)
+		}
+	}
+	bulksms.SMSList = kept
+	return excluded, nil
+}
+
+// suppressSMS applies the Suppress option to sms.
+func (c *Client) suppressSMS(ctx context.Context, sms *SMS) ([]*ExcludedRecipient, error) {
+	if c.stopList == nil {
+		return nil, nil
+	}
+	hadRecipients := strings.TrimSpace(sms.PhoneNumbers) != "" || len(sms.Recipients) > 0
+	excluded, err := c.stopList.FilterSMS(ctx, sms)
+	if err != nil {
+		return nil, err
+	}
+	if hadRecipients && sms.PhoneNumbers == "" {
+		return excluded, &AllRecipientsExcludedError{Excluded: excluded}
+	}
+	return excluded, nil
+}
+
+// suppressBulkSMS applies the Suppress and FilterNumbers options to bulksms.
+func (c *Client) suppressBulkSMS(ctx context.Context, bulksms *BulkSMS) ([]*ExcludedRecipient, error) {
+	if c.stopList == nil && c.numberFilter == nil {
+		return nil, nil
+	}
+	hadRecipients := len(bulksms.SMSList) > 0
+	var excluded []*ExcludedRecipient
+	if c.stopList != nil {
+		stopped, err := c.stopList.FilterBulkSMS(ctx, bulksms)
+		if err != nil {
+			return nil, err
+		}
+		excluded = append(excluded, stopped...)
+	}
+	if c.numberFilter != nil && len(bulksms.SMSList) > 0 {
+		filtered, err := c.numberFilter.FilterBulkSMS(ctx, bulksms)
+		if err != nil {
+			return nil, err
+		}
+		excluded = append(excluded, filtered...)
+	}
+	if hadRecipients && len(bulksms.SMSList) == 0 {
+		return excluded, &AllRecipientsExcludedError{Excluded: excluded}
+	}
+	return excluded, nil
+}
@@ -0,0 +1,182 @@
+package smspartner_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+// stopListHandler serves the stop list fixture and records the SMS sent.
+type stopListHandler struct {
+	lists int
+	sms   smspartner.SMS
+	bulk  smspartner.BulkSMS
+	t     *testing.T
+}
+
+func (h *stopListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var name string
+	switch r.URL.Path {
+	case "/v1/stop-sms/list":
+		if r.URL.Query().Get("page") != "" {
+			fmt.Fprint(w, `{"success": true, "code": 200, "nbData": 0, "data": []}`)
+			return
+		}
+		h.lists++
+		name = "stop_list.json"
+	case "/v1/send":
+		json.NewDecoder(r.Body).Decode(&h.sms)
+		name = "send_sms.json"
+	case "/v1/bulk-send":
+		json.NewDecoder(r.Body).Decode(&h.bulk)
+		name = "send_bulksms.json"
+	default:
+		h.t.Errorf("unexpected request: %s", r.URL.Path)
+		return
+	}
+	b, err := fixture(name)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	fmt.Fprint(w, string(b))
+}
+
+func TestSuppressSendSMS(t *testing.T) {
+	h := &stopListHandler{t: t}
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	stops := cli.NewStopList("")
+	cli, teardown = testingHTTPClient(t, h, smspartner.Suppress(stops))
+	defer teardown()
+
+	res, err := cli.SendSMS(&smspartner.SMS{
+		PhoneNumbers: "0620123456,06 21 12 34 56,+33622123456",
+		Message:      "Hello",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "0620123456"; h.sms.PhoneNumbers != want {
+		t.Errorf("got: %s, want: %s", h.sms.PhoneNumbers, want)
+	}
+
+	want := []*smspartner.ExcludedRecipient{
+		{PhoneNumber: "06 21 12 34 56", Reason: smspartner.ExcludedStop, Detail: "STOP received on 2018-08-17 10:24:12"},
+		{PhoneNumber: "+33622123456", Reason: smspartner.ExcludedStop, Detail: "STOP received on 2018-08-18 15:02:44"},
+	}
+	if !reflect.DeepEqual(res.Excluded, want) {
+		t.Errorf("got: %s, want: %s", jsonString(res.Excluded), jsonString(want))
+	}
+
+	// the cached list is used until it expires
+	_, err = cli.SendSMS(&smspartner.SMS{PhoneNumbers: "0621123456", Message: "Hello"})
+
+	var allErr *smspartner.AllRecipientsExcludedError
+	if !errors.As(err, &allErr) || len(allErr.Excluded) != 1 {
+		t.Errorf("got: %v, want an *AllRecipientsExcludedError", err)
+	}
+	if h.lists != 1 {
+		t.Errorf("stop list fetched %d times, want: 1", h.lists)
+	}
+}
+
+func TestSuppressSendBulkSMS(t *testing.T) {
+	h := &stopListHandler{t: t}
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	cli, teardown = testingHTTPClient(t, h, smspartner.Suppress(cli.NewStopList("")))
+	defer teardown()
+
+	res, err := cli.SendBulkSMS(&smspartner.BulkSMS{SMSList: []*smspartner.SMSPayload{
+		{PhoneNumber: "0620123456", Message: "Hello"},
+		{PhoneNumber: "0622123456", Message: "Hello"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.bulk.SMSList) != 1 || h.bulk.SMSList[0].PhoneNumber != "0620123456" {
+		t.Errorf("unexpected SMS list: %s", jsonString(h.bulk.SMSList))
+	}
+	if len(res.Excluded) != 1 || res.Excluded[0].PhoneNumber != "0622123456" {
+		t.Errorf("unexpected exclusions: %s", jsonString(res.Excluded))
+	}
+}
+
+func TestStopListFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "stops.json")
+
+	h := &stopListHandler{t: t}
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	if err := cli.NewStopList(file).Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// after a restart, the API is down but the cached copy is used
+	down := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"success": false, "code": 503, "message": "Service indisponible"}`)
+	})
+	cli, teardown = testingHTTPClient(t, down)
+	defer teardown()
+
+	stops := cli.NewStopList(file)
+	stops.MaxAge = -1
+	sms := &smspartner.SMS{PhoneNumbers: "+33621123456,0620123456"}
+	excluded, err := stops.FilterSMS(context.Background(), sms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(excluded) != 1 || sms.PhoneNumbers != "0620123456" {
+		t.Errorf("got: %s, %s", sms.PhoneNumbers, jsonString(excluded))
+	}
+
+	// without a cached copy the error is reported
+	if _, err := cli.NewStopList("").FilterSMS(context.Background(), sms); err == nil {
+		t.Error("expected a non-nil error")
+	}
+}
+
+func TestAllStops(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("page") {
+		case "":
+			b, err := fixture("stop_list.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			fmt.Fprint(w, string(b))
+		case "2":
+			fmt.Fprint(w, `{"success": true, "code": 200, "nbData": 1, "data": [{"id": 1236, "phoneNumber": "+33623123456", "createdAt": "2018-08-19 08:00:00"}]}`)
+		default:
+			fmt.Fprint(w, `{"success": true, "code": 200, "nbData": 0, "data": []}`)
+		}
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	var ids []int
+	for item, err := range cli.AllStops(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, item.ID)
+	}
+	if want := []int{1234, 1235, 1236}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got: %v, want: %v", ids, want)
+	}
+}
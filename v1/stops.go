@@ -2,8 +2,10 @@ package smspartner
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"net/http"
 )
 
@@ -20,10 +22,19 @@ type StopSMSResp struct {
 	Data     []*DataItem `json:"data,omitempty"`
 }
 
-// ListStops returns the list of numbers that sent a STOP.
+// ListStops returns the first page of the numbers that sent a STOP.
+// See ListStopsPage and AllStops.
 func (c *Client) ListStops() (*StopSMSResp, error) {
+	return c.ListStopsPage(context.Background(), 1)
+}
+
+// ListStopsPage returns a page, starting at 1, of the numbers that sent a STOP.
+func (c *Client) ListStopsPage(ctx context.Context, page int) (*StopSMSResp, error) {
 	fullURL := fmt.Sprintf("%s/stop-sms/list?apiKey=%s", c.basePath, c.apiKey)
-	req, err := http.NewRequest("GET", fullURL, nil)
+	if page > 1 {
+		fullURL += fmt.Sprintf("&page=%d", page)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -40,8 +51,88 @@ func (c *Client) ListStops() (*StopSMSResp, error) {
 	return str, nil
 }
 
+// AllStops iterates over the numbers that sent a STOP, fetching the pages
+// as needed. An error ends the iteration.
+func (c *Client) AllStops(ctx context.Context) iter.Seq2[*DataItem, error] {
+	return func(yield func(*DataItem, error) bool) {
+		seen := map[int]bool{}
+		for page := 1; ; page++ {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			resp, err := c.ListStopsPage(ctx, page)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			// The response does not tell the number of pages: stop at the
+			// first page without new stops.
+			more := false
+			for _, item := range resp.Data {
+				if item == nil || seen[item.ID] {
+					continue
+				}
+				seen[item.ID], more = true, true
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if !more {
+				return
+			}
+		}
+	}
+}
+
+type StopAddResponse struct {
+	Success bool   `json:"success"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type StopDeleteResponse struct {
+	Success bool   `json:"success"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// AddStop adds a phone number to the list of stops.
+// It returns a *RemoteAPIError if the API refuses to add it.
+func (c *Client) AddStop(phoneNumber string) (*StopAddResponse, error) {
+	blob, err := c.addToStops(context.Background(), phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	sr := new(StopAddResponse)
+	if err := json.Unmarshal(blob, sr); err != nil {
+		return nil, err
+	}
+	if !sr.Success {
+		return nil, &RemoteAPIError{Success: sr.Success, Code: sr.Code, Message: sr.Message}
+	}
+	return sr, nil
+}
+
 // AddToStops add a phone number to the list of stops.
+//
+// Deprecated: use AddStop.
 func (c *Client) AddToStops(phoneNumber string) (map[string]interface{}, error) {
+	blob, err := c.addToStops(context.Background(), phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(blob, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *Client) addToStops(ctx context.Context, phoneNumber string) ([]byte, error) {
 	var payload struct {
 		APIKey      string `json:"apiKey,omitempty"`
 		PhoneNumber string `json:"phoneNumber,omitempty"`
@@ -54,32 +145,37 @@ func (c *Client) AddToStops(phoneNumber string) (map[string]interface{}, error)
 	}
 	fullURL := fmt.Sprintf("%s/stop-sms/add", c.basePath)
 
-	req, err := http.NewRequest("POST", fullURL, bytes.NewReader(blob))
+	req, err := http.NewRequestWithContext(ctx, "POST", fullURL, bytes.NewReader(blob))
 	if err != nil {
 		return nil, err
 	}
+	return c.doRequest(req)
+}
 
-	blob, err = c.doRequest(req)
+// DeleteStop deletes a phone number from the list of stops, by the ID of
+// its DataItem.
+// It returns a *RemoteAPIError if the API refuses to delete it.
+func (c *Client) DeleteStop(id int) (*StopDeleteResponse, error) {
+	res, err := c.deleteFromStops(context.Background(), id)
 	if err != nil {
 		return nil, err
 	}
 
-	var m map[string]interface{}
-	if err := json.Unmarshal(blob, &m); err != nil {
+	sr := new(StopDeleteResponse)
+	if err := json.Unmarshal(res, sr); err != nil {
 		return nil, err
 	}
-	return m, nil
+	if !sr.Success {
+		return nil, &RemoteAPIError{Success: sr.Success, Code: sr.Code, Message: sr.Message}
+	}
+	return sr, nil
 }
 
 // DeleteFromStops Deletes a phone number from the list of stops.
+//
+// Deprecated: use DeleteStop.
 func (c *Client) DeleteFromStops(id int) (map[string]interface{}, error) {
-	fullURL := fmt.Sprintf("%s/stop-sms/delete?apiKey=%s&id=%d", c.basePath, c.apiKey, id)
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	res, err := c.doRequest(req)
+	res, err := c.deleteFromStops(context.Background(), id)
 	if err != nil {
 		return nil, err
 	}
@@ -90,3 +186,12 @@ func (c *Client) DeleteFromStops(id int) (map[string]interface{}, error) {
 	}
 	return m, nil
 }
+
+func (c *Client) deleteFromStops(ctx context.Context, id int) ([]byte, error) {
+	fullURL := fmt.Sprintf("%s/stop-sms/delete?apiKey=%s&id=%d", c.basePath, c.apiKey, id)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.doRequest(req)
+}
@@ -2,10 +2,13 @@ package smspartner
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"iter"
 	"net/http"
+	"strings"
 )
 
 type SubAccountType string
@@ -83,11 +86,20 @@ type SubAccount struct {
 	APIKey    string `json:"apiKey"`
 	CreatedAt string `json:"createdAt"`
 	Credits   struct {
-		Balance  string `json:"balance"`
+		Balance  Money  `json:"balance"`
 		Currency string `json:"currency"`
 	} `json:"credits"`
 }
 
+func (sub *SubAccount) UnmarshalJSON(b []byte) error {
+	type subAccount SubAccount
+	if err := json.Unmarshal(b, (*subAccount)(sub)); err != nil {
+		return err
+	}
+	sub.Credits.Balance.Currency = sub.Credits.Currency
+	return nil
+}
+
 type SubAccountsResponse struct {
 	Success   bool          `json:"success"`
 	Code      int           `json:"code"`
@@ -98,10 +110,19 @@ type SubAccountsResponse struct {
 	Data      []*SubAccount `json:"data"`
 }
 
-// ListSubAccounts lists all sub accounts.
+// ListSubAccounts returns the first page of the sub accounts.
+// See ListSubAccountsPage and AllSubAccounts.
 func (c *Client) ListSubAccounts() (*SubAccountsResponse, error) {
+	return c.ListSubAccountsPage(context.Background(), 1)
+}
+
+// ListSubAccountsPage returns a page, starting at 1, of the sub accounts.
+func (c *Client) ListSubAccountsPage(ctx context.Context, page int) (*SubAccountsResponse, error) {
 	fullURL := fmt.Sprintf("%s/subaccount/list?apiKey=%s", c.basePath, c.apiKey)
-	req, err := http.NewRequest("GET", fullURL, nil)
+	if page > 1 {
+		fullURL += fmt.Sprintf("&page=%d", page)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -118,17 +139,60 @@ func (c *Client) ListSubAccounts() (*SubAccountsResponse, error) {
 	return subAccsResp, nil
 }
 
+// AllSubAccounts iterates over the sub accounts, fetching the pages as
+// needed. An error ends the iteration.
+func (c *Client) AllSubAccounts(ctx context.Context) iter.Seq2[*SubAccount, error] {
+	return func(yield func(*SubAccount, error) bool) {
+		for page := 1; ; page++ {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			resp, err := c.ListSubAccountsPage(ctx, page)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			for _, sub := range resp.Data {
+				if sub == nil {
+					continue
+				}
+				if !yield(sub, nil) {
+					return
+				}
+			}
+			if len(resp.Data) == 0 || resp.NbPerPage <= 0 || page*resp.NbPerPage >= resp.Total {
+				return
+			}
+		}
+	}
+}
+
 type SubAccountCreditAdditionResponse struct {
-	Success          bool    `json:"success"`
-	Code             int     `json:"code"`
-	Message          string  `json:"message"`
-	Credit           float64 `json:"total"`
-	SubaccountCredit float64 `json:"subaccountCredit"`
-	Currency         string  `json:"currency"`
+	Success          bool   `json:"success"`
+	Code             int    `json:"code"`
+	Message          string `json:"message"`
+	Credit           Money  `json:"total"`
+	SubaccountCredit Money  `json:"subaccountCredit"`
+	Currency         string `json:"currency"`
+}
+
+func (r *SubAccountCreditAdditionResponse) UnmarshalJSON(b []byte) error {
+	type response SubAccountCreditAdditionResponse
+	if err := json.Unmarshal(b, (*response)(r)); err != nil {
+		return err
+	}
+	r.Credit.Currency, r.SubaccountCredit.Currency = r.Currency, r.Currency
+	return nil
 }
 
 // AddCreditToSubAccount - Credits will be debited from the main account.
 func (c *Client) AddCreditToSubAccount(credit, tokenSubaccount string) (*SubAccountCreditAdditionResponse, error) {
+	return c.addCreditToSubAccount(context.Background(), credit, tokenSubaccount)
+}
+
+func (c *Client) addCreditToSubAccount(ctx context.Context, credit, tokenSubaccount string) (*SubAccountCreditAdditionResponse, error) {
 	var payload struct {
 		APIKey          string `json:"apiKey,omitempty"`
 		Credit          string `json:"credit,omitempty"`
@@ -144,7 +208,7 @@ func (c *Client) AddCreditToSubAccount(credit, tokenSubaccount string) (*SubAcco
 	}
 	fullURL := fmt.Sprintf("%s/subaccount/credit/add", c.basePath)
 
-	req, err := http.NewRequest("POST", fullURL, bytes.NewReader(blob))
+	req, err := http.NewRequestWithContext(ctx, "POST", fullURL, bytes.NewReader(blob))
 	if err != nil {
 		return nil, err
 	}
@@ -161,8 +225,109 @@ func (c *Client) AddCreditToSubAccount(credit, tokenSubaccount string) (*SubAcco
 	return sr, nil
 }
 
-// DeleteCreditFromSubAccount -
-// REVIEW: https://my.smspartner.fr/documentation-fr/api/v1/subaccount/credit/remove
-func (c *Client) DeleteCreditFromSubAccount() {
-	panic("Not implemented yet!")
+type SubAccountCreditRemovalResponse struct {
+	Success          bool   `json:"success"`
+	Code             int    `json:"code"`
+	Message          string `json:"message"`
+	Credit           Money  `json:"total"`
+	SubaccountCredit Money  `json:"subaccountCredit"`
+	Currency         string `json:"currency"`
+}
+
+func (r *SubAccountCreditRemovalResponse) UnmarshalJSON(b []byte) error {
+	type response SubAccountCreditRemovalResponse
+	if err := json.Unmarshal(b, (*response)(r)); err != nil {
+		return err
+	}
+	r.Credit.Currency, r.SubaccountCredit.Currency = r.Currency, r.Currency
+	return nil
+}
+
+var ErrInvalidCredit = errors.New("Credit must be a positive amount")
+
+// DeleteCreditFromSubAccount - Credits will be given back to the main account.
+func (c *Client) DeleteCreditFromSubAccount(credit, tokenSubaccount string) (*SubAccountCreditRemovalResponse, error) {
+	return c.deleteCreditFromSubAccount(context.Background(), credit, tokenSubaccount)
+}
+
+func (c *Client) deleteCreditFromSubAccount(ctx context.Context, credit, tokenSubaccount string) (*SubAccountCreditRemovalResponse, error) {
+	amount, err := ParseMoney(credit, "")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredit, err)
+	}
+	if amount.Sign() <= 0 {
+		return nil, ErrInvalidCredit
+	}
+
+	var payload struct {
+		APIKey          string `json:"apiKey,omitempty"`
+		Credit          string `json:"credit,omitempty"`
+		TokenSubAccount string `json:"tokenSubaccount,omitempty"`
+	}
+	payload.APIKey = c.apiKey
+	payload.Credit = amount.Amount()
+	payload.TokenSubAccount = tokenSubaccount
+
+	blob, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	fullURL := fmt.Sprintf("%s/subaccount/credit/remove", c.basePath)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fullURL, bytes.NewReader(blob))
+	if err != nil {
+		return nil, err
+	}
+
+	blob, err = c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	sr := new(SubAccountCreditRemovalResponse)
+	if err := json.Unmarshal(blob, &sr); err != nil {
+		return nil, err
+	}
+	return sr, nil
+}
+
+var ErrSubAccountAPIKey = errors.New("Sub-account has no API key")
+
+// ForSubAccount returns a client acting as sub, sharing the HTTP client and
+// settings of c. The stop list, schedule store and budget of c belong to the
+// main account and are not shared: set them again with opts if needed. The
+// ledger of the RecordCosts option is shared, with the token of sub recorded.
+// The number filter of the FilterNumbers option is kept, but looks up the
+// numbers as sub, which is billed for them.
+func (c *Client) ForSubAccount(sub *SubAccount, opts ...Option) (*Client, error) {
+	if sub == nil || strings.TrimSpace(sub.APIKey) == "" {
+		return nil, ErrSubAccountAPIKey
+	}
+
+	child := *c
+	child.apiKey, child.subAccount = sub.APIKey, sub.Token
+	child.stopList, child.schedules, child.budget = nil, nil, nil
+	if c.numberFilter != nil {
+		filter := *c.numberFilter
+		filter.client = &child
+		child.numberFilter = &filter
+	}
+	if err := child.parseOptions(opts...); err != nil {
+		return nil, err
+	}
+	return &child, nil
+}
+
+// ForSubAccountToken is like ForSubAccount for the sub account with the given
+// token, looked up with AllSubAccounts.
+func (c *Client) ForSubAccountToken(ctx context.Context, token string, opts ...Option) (*Client, error) {
+	for sub, err := range c.AllSubAccounts(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		if sub.Token == token {
+			return c.ForSubAccount(sub, opts...)
+		}
+	}
+	return nil, fmt.Errorf("no sub-account with token %q", token)
 }
@@ -0,0 +1,199 @@
+package smspartner_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+func TestDeleteCreditFromSubAccount(t *testing.T) {
+	var got map[string]string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/subaccount/credit/remove" {
+			t.Errorf("unexpected request: %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		b, err := fixture("subaccount_credit_remove.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	res, err := cli.DeleteCreditFromSubAccount("10.5", "a1b2c3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got["credit"] != "10.5" || got["tokenSubaccount"] != "a1b2c3" {
+		t.Errorf("unexpected payload: %v", got)
+	}
+	if res.SubaccountCredit != smspartner.MustParseMoney("49.5", "EUR") {
+		t.Errorf("unexpected response: %#v", res)
+	}
+}
+
+func TestDeleteCreditFromSubAccountWithInvalidCredit(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s", r.URL.Path)
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	for _, credit := range []string{"0", "-5", "", "ten", "NaN"} {
+		if _, err := cli.DeleteCreditFromSubAccount(credit, "a1b2c3"); !errors.Is(err, smspartner.ErrInvalidCredit) {
+			t.Errorf("%q: got: %v, want: %v", credit, err, smspartner.ErrInvalidCredit)
+		}
+	}
+}
+
+// subAccountsHandler serves two pages of sub accounts, or an error for the
+// second page if failing is set.
+func subAccountsHandler(t *testing.T, failing bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		name := "subaccount_list.json"
+		switch page := r.URL.Query().Get("page"); {
+		case page == "2" && failing:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"success": false, "code": 500, "message": "Erreur interne"}`)
+			return
+		case page == "2":
+			name = "subaccount_list_page2.json"
+		case page != "":
+			t.Errorf("unexpected page: %s", page)
+		}
+		b, err := fixture(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+}
+
+func TestAllSubAccounts(t *testing.T) {
+	cli, teardown := testingHTTPClient(t, subAccountsHandler(t, false))
+	defer teardown()
+
+	var tokens []string
+	for sub, err := range cli.AllSubAccounts(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, sub.Token)
+	}
+	if want := []string{"a1b2c3", "d4e5f6", "g7h8i9"}; fmt.Sprint(tokens) != fmt.Sprint(want) {
+		t.Errorf("got: %v, want: %v", tokens, want)
+	}
+
+	res, err := cli.ListSubAccountsPage(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Page != 2 || len(res.Data) != 1 {
+		t.Errorf("unexpected page: %#v", res)
+	}
+}
+
+func TestAllSubAccountsWithError(t *testing.T) {
+	cli, teardown := testingHTTPClient(t, subAccountsHandler(t, true))
+	defer teardown()
+
+	var n int
+	var gotErr error
+	for sub, err := range cli.AllSubAccounts(context.Background()) {
+		if err != nil {
+			gotErr = err
+			continue
+		}
+		if sub == nil {
+			t.Fatal("unexpected nil sub account")
+		}
+		n++
+	}
+	var remAPIErr *smspartner.RemoteAPIError
+	if n != 2 || !errors.As(gotErr, &remAPIErr) {
+		t.Errorf("got %d sub accounts and error %v", n, gotErr)
+	}
+
+	// cancelling the context stops before the next page
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n, gotErr = 0, nil
+	for _, err := range cli.AllSubAccounts(ctx) {
+		if err != nil {
+			gotErr = err
+			continue
+		}
+		n++
+		cancel()
+	}
+	if n != 2 || gotErr != context.Canceled {
+		t.Errorf("got %d sub accounts and error %v", n, gotErr)
+	}
+}
+
+func TestForSubAccount(t *testing.T) {
+	var apiKeys []string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		apiKeys = append(apiKeys, r.URL.Query().Get("apiKey"))
+
+		name := "credits.json"
+		switch r.URL.Path {
+		case "/v1/subaccount/list":
+			name = "subaccount_list.json"
+			if r.URL.Query().Get("page") == "2" {
+				name = "subaccount_list_page2.json"
+			}
+		case "/v1/me":
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+		}
+		b, err := fixture(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+
+	cli, teardown := testingHTTPClient(t, h, smspartner.APIKey("main-api-key"))
+	defer teardown()
+
+	sub, err := cli.ForSubAccountToken(context.Background(), "g7h8i9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.CheckCredits(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.CheckCredits(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"main-api-key", "main-api-key", "sub-api-key-3", "main-api-key"}
+	if fmt.Sprint(apiKeys) != fmt.Sprint(want) {
+		t.Errorf("got: %v, want: %v", apiKeys, want)
+	}
+
+	if _, err := cli.ForSubAccountToken(context.Background(), "unknown"); err == nil {
+		t.Error("expected a non-nil error for an unknown token")
+	}
+	if _, err := cli.ForSubAccount(&smspartner.SubAccount{Token: "a1b2c3"}); err != smspartner.ErrSubAccountAPIKey {
+		t.Errorf("got: %v, want: %v", err, smspartner.ErrSubAccountAPIKey)
+	}
+}
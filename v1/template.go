@@ -0,0 +1,188 @@
+package smspartner
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var placeholderName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// MessageTemplate is a message with {{name}} placeholders, replaced by the
+// fields of each recipient when rendered.
+type MessageTemplate struct {
+	text  string
+	parts []templatePart
+	vars  []string
+}
+
+type templatePart struct {
+	text string
+	name string // placeholder name, empty for literal text
+}
+
+// ParseTemplate parses a message template such as "Bonjour {{firstname}} !".
+func ParseTemplate(text string) (*MessageTemplate, error) {
+	t := &MessageTemplate{text: text}
+	seen := map[string]bool{}
+
+	rest := text
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("template %q: unclosed placeholder", text)
+		}
+		name := strings.TrimSpace(rest[start+2 : start+end])
+		if !placeholderName.MatchString(name) {
+			return nil, fmt.Errorf("template %q: invalid placeholder name %q", text, name)
+		}
+
+		if start > 0 {
+			t.parts = append(t.parts, templatePart{text: rest[:start]})
+		}
+		t.parts = append(t.parts, templatePart{name: name})
+		if !seen[name] {
+			seen[name] = true
+			t.vars = append(t.vars, name)
+		}
+		rest = rest[start+end+2:]
+	}
+	if rest != "" {
+		t.parts = append(t.parts, templatePart{text: rest})
+	}
+	sort.Strings(t.vars)
+	return t, nil
+}
+
+// String returns the source text of the template.
+func (t *MessageTemplate) String() string {
+	return t.text
+}
+
+// Variables returns the sorted names of the placeholders of the template.
+func (t *MessageTemplate) Variables() []string {
+	return append([]string(nil), t.vars...)
+}
+
+// Missing returns the sorted placeholders that fields does not define.
+func (t *MessageTemplate) Missing(fields map[string]string) []string {
+	var missing []string
+	for _, name := range t.vars {
+		if _, ok := fields[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// Render returns the message with every placeholder replaced by its field.
+func (t *MessageTemplate) Render(fields map[string]string) (string, error) {
+	if missing := t.Missing(fields); len(missing) > 0 {
+		return "", fmt.Errorf("missing template variables: %s", strings.Join(missing, ", "))
+	}
+
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.name != "" {
+			b.WriteString(fields[p.name])
+		} else {
+			b.WriteString(p.text)
+		}
+	}
+	return b.String(), nil
+}
+
+// TemplateRecord is a recipient of a personalized bulk send.
+type TemplateRecord struct {
+	PhoneNumber string
+	Fields      map[string]string
+}
+
+// MissingVariables lists the placeholders a recipient does not define.
+type MissingVariables struct {
+	Index       int
+	PhoneNumber string
+	Missing     []string
+}
+
+// MissingVariablesError is returned when some recipients can not be rendered.
+type MissingVariablesError struct {
+	Recipients []*MissingVariables
+}
+
+func (e *MissingVariablesError) Error() string {
+	first := e.Recipients[0]
+	msg := fmt.Sprintf("recipient %s is missing %s", first.PhoneNumber, strings.Join(first.Missing, ", "))
+	switch n := len(e.Recipients); n {
+	case 1:
+		return msg
+	case 2:
+		return msg + " (and 1 other recipient)"
+	default:
+		return fmt.Sprintf("%s (and %d other recipients)", msg, n-1)
+	}
+}
+
+// RenderedBulk is the result of rendering a template for every recipient.
+type RenderedBulk struct {
+	SMSList []*SMSPayload
+	// Longest is the rendered message needing the most SMS, and
+	// LongestIndex its position in SMSList.
+	Longest      MessageInfo
+	LongestIndex int
+}
+
+// RenderBulk renders the template for every record. Nothing is rendered
+// unless all the records define all the placeholders, in which case the
+// error is a *MissingVariablesError.
+func (t *MessageTemplate) RenderBulk(records []*TemplateRecord) (*RenderedBulk, error) {
+	missingErr := new(MissingVariablesError)
+	for i, rec := range records {
+		if missing := t.Missing(rec.Fields); len(missing) > 0 {
+			missingErr.Recipients = append(missingErr.Recipients, &MissingVariables{
+				Index:       i,
+				PhoneNumber: rec.PhoneNumber,
+				Missing:     missing,
+			})
+		}
+	}
+	if len(missingErr.Recipients) > 0 {
+		return nil, missingErr
+	}
+
+	rb := &RenderedBulk{SMSList: make([]*SMSPayload, len(records))}
+	for i, rec := range records {
+		msg, err := t.Render(rec.Fields)
+		if err != nil {
+			return nil, err
+		}
+		rb.SMSList[i] = &SMSPayload{PhoneNumber: rec.PhoneNumber, Message: msg}
+
+		info := CountSegments(msg)
+		if info.Segments > rb.Longest.Segments || (info.Segments == rb.Longest.Segments && info.Length > rb.Longest.Length) {
+			rb.Longest, rb.LongestIndex = info, i
+		}
+	}
+	return rb, nil
+}
+
+// SendPersonalizedBulkSMS renders tpl for every record and sends the messages
+// with the sending parameters of bulksms (its SMSList is replaced).
+func (c *Client) SendPersonalizedBulkSMS(tpl *MessageTemplate, records []*TemplateRecord, bulksms *BulkSMS) (*BulkSMSResponse, *RenderedBulk, error) {
+	rb, err := tpl.RenderBulk(records)
+	if err != nil {
+		return nil, nil, err
+	}
+	bulksms.SMSList = rb.SMSList
+
+	resp, err := c.SendBulkSMS(bulksms)
+	if err != nil {
+		return nil, rb, err
+	}
+	return resp, rb, nil
+}
@@ -0,0 +1,134 @@
+package smspartner_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+func TestCountSegments(t *testing.T) {
+	tests := [...]struct {
+		msg  string
+		want smspartner.MessageInfo
+	}{
+		{"", smspartner.MessageInfo{Encoding: smspartner.GSM7}},
+		{"Bonjour à tous", smspartner.MessageInfo{Encoding: smspartner.GSM7, Length: 14, Segments: 1}},
+		{strings.Repeat("a", 160), smspartner.MessageInfo{Encoding: smspartner.GSM7, Length: 160, Segments: 1}},
+		{strings.Repeat("a", 161), smspartner.MessageInfo{Encoding: smspartner.GSM7, Length: 161, Segments: 2}},
+		{strings.Repeat("€", 80), smspartner.MessageInfo{Encoding: smspartner.GSM7, Length: 160, Segments: 1}},
+		{"Ça coûte 5€", smspartner.MessageInfo{Encoding: smspartner.UCS2, Length: 11, Segments: 1}},
+		{strings.Repeat("ê", 71), smspartner.MessageInfo{Encoding: smspartner.UCS2, Length: 71, Segments: 2}},
+		{"👍", smspartner.MessageInfo{Encoding: smspartner.UCS2, Length: 2, Segments: 1}},
+	}
+
+	for i, tt := range tests {
+		if got := smspartner.CountSegments(tt.msg); got != tt.want {
+			t.Errorf("#%d. got: %+v, want: %+v", i, got, tt.want)
+		}
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	tpl, err := smspartner.ParseTemplate("Bonjour {{ firstname }}, votre code est {{code}}. A bientot {{firstname}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tpl.Variables(), []string{"code", "firstname"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+
+	got, err := tpl.Render(map[string]string{"firstname": "Léa", "code": "1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Bonjour Léa, votre code est 1234. A bientot Léa"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	for _, text := range []string{"Bonjour {{firstname", "Bonjour {{first name}}", "{{}}"} {
+		if _, err := smspartner.ParseTemplate(text); err == nil {
+			t.Errorf("%q: expected a non-nil error", text)
+		}
+	}
+}
+
+func TestRenderBulkMissingVariables(t *testing.T) {
+	tpl, err := smspartner.ParseTemplate("Bonjour {{firstname}} {{lastname}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []*smspartner.TemplateRecord{
+		{PhoneNumber: "0620123456", Fields: map[string]string{"firstname": "Léa", "lastname": "Martin"}},
+		{PhoneNumber: "0621123456", Fields: map[string]string{"firstname": "Hugo"}},
+		{PhoneNumber: "0622123456"},
+	}
+	_, err = tpl.RenderBulk(records)
+
+	var missingErr *smspartner.MissingVariablesError
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("got: %v, want a *MissingVariablesError", err)
+	}
+	if len(missingErr.Recipients) != 2 {
+		t.Fatalf("got: %d recipients, want: %d", len(missingErr.Recipients), 2)
+	}
+	if got := missingErr.Recipients[1].Missing; !reflect.DeepEqual(got, []string{"firstname", "lastname"}) {
+		t.Errorf("got: %v", got)
+	}
+	if want := "recipient 0621123456 is missing lastname (and 1 other recipient)"; err.Error() != want {
+		t.Errorf("got: %s, want: %s", err, want)
+	}
+}
+
+func TestSendPersonalizedBulkSMS(t *testing.T) {
+	var got []*smspartner.SMSPayload
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req smspartner.BulkSMS
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		got = req.SMSList
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		b, err := fixture("send_bulksms.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, string(b))
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	tpl, err := smspartner.ParseTemplate("Bonjour {{firstname}}, votre colis arrive {{day}}.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := []*smspartner.TemplateRecord{
+		{PhoneNumber: "0620123456", Fields: map[string]string{"firstname": "Léa", "day": "demain"}},
+		{PhoneNumber: "0621123456", Fields: map[string]string{"firstname": "Zoë", "day": "mercredi"}},
+	}
+
+	_, rb, err := cli.SendPersonalizedBulkSMS(tpl, records, &smspartner.BulkSMS{Gamme: smspartner.Premium})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*smspartner.SMSPayload{
+		{PhoneNumber: "0620123456", Message: "Bonjour Léa, votre colis arrive demain."},
+		{PhoneNumber: "0621123456", Message: "Bonjour Zoë, votre colis arrive mercredi."},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+	if rb.LongestIndex != 1 || rb.Longest.Encoding != smspartner.UCS2 || rb.Longest.Segments != 1 {
+		t.Errorf("unexpected longest message: #%d %+v", rb.LongestIndex, rb.Longest)
+	}
+}
@@ -0,0 +1,252 @@
+package smspartner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TopUpRule tops up a sub account with Amount when its balance falls below
+// Threshold.
+type TopUpRule struct {
+	Threshold Money
+	Amount    Money
+}
+
+// Reasons of the transfers skipped by a top-up.
+const (
+	TopUpSkippedDailyCap    = "daily cap reached"
+	TopUpSkippedMainBalance = "main account balance too low"
+	TopUpSkippedCurrency    = "threshold in another currency than the balance"
+)
+
+// TopUpEntry is a line of the top-up journal: a transfer to a sub account,
+// or the reason why it has not been made. A transfer is journaled twice,
+// pending before it is made and with the same ID once it is done.
+type TopUpEntry struct {
+	Time      time.Time `json:"time"`
+	ID        string    `json:"id,omitempty"`
+	Pending   bool      `json:"pending,omitempty"`
+	Token     string    `json:"token"`
+	Email     string    `json:"email,omitempty"`
+	Balance   Money     `json:"balance"`
+	Threshold Money     `json:"threshold"`
+	Amount    Money     `json:"amount"`
+	DryRun    bool      `json:"dryRun,omitempty"`
+	Skipped   string    `json:"skipped,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// transferred reports whether the entry moved credits.
+func (e *TopUpEntry) transferred() bool {
+	return !e.DryRun && e.Skipped == "" && e.Error == ""
+}
+
+// TopUp moves credits from the main account to the sub accounts whose
+// balance is low. Every transfer, skipped or failed transfer is appended to
+// the JSON lines file Journal, which is also read to enforce DailyCap. A
+// transfer still pending in the journal, e.g. after a crash, counts toward
+// DailyCap.
+type TopUp struct {
+	Rules    map[string]TopUpRule // by sub account token
+	Default  *TopUpRule           // for the sub accounts without a rule, if not nil
+	DailyCap Money                // maximum transferred per day, no limit if zero
+	Journal  string
+	DryRun   bool // journal the transfers without making them
+
+	// OnError is called with the errors of the checks made by Run.
+	OnError func(error)
+
+	client *Client
+	mu     sync.Mutex
+}
+
+// NewTopUp returns a top-up of the sub accounts of c, journaled in journal.
+func (c *Client) NewTopUp(journal string) *TopUp {
+	return &TopUp{Rules: map[string]TopUpRule{}, Journal: journal, client: c}
+}
+
+func (t *TopUp) rule(token string) (TopUpRule, bool) {
+	if r, ok := t.Rules[token]; ok {
+		return r, true
+	}
+	if t.Default != nil {
+		return *t.Default, true
+	}
+	return TopUpRule{}, false
+}
+
+// Run checks the sub accounts every interval until ctx is done.
+func (t *TopUp) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := t.Check(ctx); err != nil && t.OnError != nil && ctx.Err() == nil {
+			t.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check tops up the sub accounts whose balance is below the threshold of
+// their rule and returns the journal entries written.
+func (t *TopUp) Check(ctx context.Context) ([]*TopUpEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	spent, err := t.spentToday()
+	if err != nil {
+		return nil, err
+	}
+	credits, err := t.client.checkCredits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if credits.Credits == nil {
+		return nil, fmt.Errorf("unexpected response: no credits")
+	}
+	mainBalance := credits.Credits.Balance
+
+	var entries []*TopUpEntry
+	for sub, err := range t.client.AllSubAccounts(ctx) {
+		if err != nil {
+			return entries, err
+		}
+		r, ok := t.rule(sub.Token)
+		if !ok || r.Amount.Sign() <= 0 {
+			continue
+		}
+		balance := sub.Credits.Balance
+		n, cmpErr := balance.Cmp(r.Threshold)
+		if cmpErr == nil && n >= 0 {
+			continue
+		}
+		after, err := spent.Add(r.Amount)
+		if err != nil {
+			return entries, fmt.Errorf("sub-account %s: %v", sub.Token, err)
+		}
+
+		e := &TopUpEntry{
+			Time:      time.Now(),
+			Token:     sub.Token,
+			Email:     sub.Email,
+			Balance:   balance,
+			Threshold: r.Threshold,
+			Amount:    r.Amount,
+			DryRun:    t.DryRun,
+		}
+		switch {
+		case cmpErr != nil:
+			e.Skipped = TopUpSkippedCurrency
+		case !t.DailyCap.IsZero() && exceeds(after, t.DailyCap):
+			e.Skipped = TopUpSkippedDailyCap
+		case exceeds(r.Amount, mainBalance):
+			e.Skipped = TopUpSkippedMainBalance
+		case !t.DryRun:
+			e.ID = fmt.Sprintf("%s-%d", sub.Token, e.Time.UnixNano())
+			pending := *e
+			pending.Pending = true
+			if err := t.write(&pending); err != nil {
+				return entries, err
+			}
+			if _, err := t.client.addCreditToSubAccount(ctx, r.Amount.Amount(), sub.Token); err != nil {
+				e.Error = err.Error()
+			}
+			e.Time = time.Now()
+		}
+		if e.Skipped == "" && e.Error == "" {
+			spent = after
+			mainBalance, _ = mainBalance.Sub(r.Amount)
+		}
+
+		if err := t.write(e); err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// spentToday returns the amount transferred since midnight, in the time
+// zone of the API, according to the journal.
+func (t *TopUp) spentToday() (Money, error) {
+	f, err := os.Open(t.Journal)
+	if os.IsNotExist(err) {
+		return Money{}, nil
+	}
+	if err != nil {
+		return Money{}, err
+	}
+	defer f.Close()
+
+	now := time.Now().In(apiLocation)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, apiLocation)
+
+	var spent Money
+	counted := map[string]bool{} // pending transfers counted in spent
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+		var e TopUpEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return Money{}, fmt.Errorf("error reading journal %s, line %d: %v", t.Journal, line, err)
+		}
+		var amount Money
+		switch {
+		case e.Pending:
+			if !e.Time.Before(midnight) {
+				amount = e.Amount
+				counted[e.ID] = true
+			}
+		case e.ID != "":
+			// The transfer was counted when it started, unless it failed.
+			if e.Error != "" && counted[e.ID] {
+				amount = e.Amount.Neg()
+			}
+		case e.transferred() && !e.Time.Before(midnight):
+			amount = e.Amount
+		}
+		var err error
+		if spent, err = spent.Add(amount); err != nil {
+			return Money{}, fmt.Errorf("error reading journal %s, line %d: %v", t.Journal, line, err)
+		}
+	}
+	return spent, sc.Err()
+}
+
+func (t *TopUp) write(e *TopUpEntry) error {
+	blob, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(t.Journal, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(blob, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exceeds reports whether a is greater than b, or of another currency.
+func exceeds(a, b Money) bool {
+	n, err := a.Cmp(b)
+	return err != nil || n > 0
+}
@@ -0,0 +1,224 @@
+package smspartner_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+// topUpHandler serves the sub accounts fixtures and a main account balance,
+// and records the credit transfers.
+type topUpHandler struct {
+	balance   string
+	transfers []string // token:credit
+	fail      bool     // reject the transfers
+	t         *testing.T
+}
+
+func (h *topUpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var name string
+	switch r.URL.Path {
+	case "/v1/me":
+		fmt.Fprintf(w, `{"success": true, "code": 200, "credits": {"balance": %q, "currency": "EUR"}}`, h.balance)
+		return
+	case "/v1/subaccount/list":
+		name = "subaccount_list.json"
+		if r.URL.Query().Get("page") == "2" {
+			name = "subaccount_list_page2.json"
+		}
+	case "/v1/subaccount/credit/add":
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		if h.fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"success": false, "code": 10, "message": "Erreur interne"}`)
+			return
+		}
+		h.transfers = append(h.transfers, payload["tokenSubaccount"]+":"+payload["credit"])
+		fmt.Fprint(w, `{"success": true, "code": 200, "message": "Crédit ajouté"}`)
+		return
+	default:
+		h.t.Errorf("unexpected request: %s", r.URL.Path)
+		return
+	}
+	b, err := fixture(name)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	fmt.Fprint(w, string(b))
+}
+
+func TestTopUpDailyCap(t *testing.T) {
+	h := &topUpHandler{balance: "100", t: t}
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	journal := filepath.Join(t.TempDir(), "topup.jsonl")
+	topUp := cli.NewTopUp(journal)
+	topUp.Rules["a1b2c3"] = smspartner.TopUpRule{Threshold: smspartner.MustParseMoney("60", ""), Amount: smspartner.MustParseMoney("50", "")}
+	topUp.Default = &smspartner.TopUpRule{Threshold: smspartner.MustParseMoney("20", ""), Amount: smspartner.MustParseMoney("30", "")}
+	topUp.DailyCap = smspartner.MustParseMoney("100", "")
+
+	entries, err := topUp.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a1b2c3:50", "d4e5f6:30"}; fmt.Sprint(h.transfers) != fmt.Sprint(want) {
+		t.Errorf("got: %v, want: %v", h.transfers, want)
+	}
+	if len(entries) != 3 || entries[2].Token != "g7h8i9" || entries[2].Skipped != smspartner.TopUpSkippedDailyCap {
+		t.Errorf("unexpected entries: %s", jsonString(entries))
+	}
+
+	// the amount already transferred today is read from the journal
+	topUp = cli.NewTopUp(journal)
+	topUp.Default = &smspartner.TopUpRule{Threshold: smspartner.MustParseMoney("20", ""), Amount: smspartner.MustParseMoney("20", "")}
+	topUp.DailyCap = smspartner.MustParseMoney("100", "")
+	if _, err := topUp.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a1b2c3:50", "d4e5f6:30", "d4e5f6:20"}; fmt.Sprint(h.transfers) != fmt.Sprint(want) {
+		t.Errorf("got: %v, want: %v", h.transfers, want)
+	}
+
+	blob, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each transfer is journaled pending, then done
+	if n := strings.Count(string(blob), "\n"); n != 8 {
+		t.Errorf("got %d journal entries, want: 8", n)
+	}
+}
+
+func TestTopUpMainBalance(t *testing.T) {
+	h := &topUpHandler{balance: "40.000", t: t}
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	topUp := cli.NewTopUp(filepath.Join(t.TempDir(), "topup.jsonl"))
+	topUp.Rules["a1b2c3"] = smspartner.TopUpRule{Threshold: smspartner.MustParseMoney("60", ""), Amount: smspartner.MustParseMoney("50", "")}
+	topUp.Default = &smspartner.TopUpRule{Threshold: smspartner.MustParseMoney("20", ""), Amount: smspartner.MustParseMoney("30", "")}
+
+	entries, err := topUp.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"d4e5f6:30"}; fmt.Sprint(h.transfers) != fmt.Sprint(want) {
+		t.Errorf("got: %v, want: %v", h.transfers, want)
+	}
+	for _, i := range []int{0, 2} {
+		if entries[i].Skipped != smspartner.TopUpSkippedMainBalance {
+			t.Errorf("unexpected entry: %s", jsonString(entries[i]))
+		}
+	}
+}
+
+func TestTopUpCurrency(t *testing.T) {
+	h := &topUpHandler{balance: "100", t: t}
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	topUp := cli.NewTopUp(filepath.Join(t.TempDir(), "topup.jsonl"))
+	topUp.Rules["a1b2c3"] = smspartner.TopUpRule{Threshold: smspartner.MustParseMoney("60", "USD"), Amount: smspartner.MustParseMoney("50", "")}
+
+	entries, err := topUp.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.transfers) != 0 {
+		t.Errorf("unexpected transfers: %v", h.transfers)
+	}
+	if len(entries) != 1 || entries[0].Skipped != smspartner.TopUpSkippedCurrency {
+		t.Errorf("unexpected entries: %s", jsonString(entries))
+	}
+}
+
+func TestTopUpDryRun(t *testing.T) {
+	h := &topUpHandler{balance: "100", t: t}
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	journal := filepath.Join(t.TempDir(), "topup.jsonl")
+	topUp := cli.NewTopUp(journal)
+	topUp.Default = &smspartner.TopUpRule{Threshold: smspartner.MustParseMoney("20", ""), Amount: smspartner.MustParseMoney("30", "")}
+	topUp.DailyCap = smspartner.MustParseMoney("60", "")
+	topUp.DryRun = true
+
+	entries, err := topUp.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.transfers) != 0 {
+		t.Errorf("unexpected transfers: %v", h.transfers)
+	}
+	if len(entries) != 2 || !entries[0].DryRun || entries[0].Skipped != "" || entries[1].Skipped != "" {
+		t.Errorf("unexpected entries: %s", jsonString(entries))
+	}
+
+	// dry runs do not count towards the daily cap
+	topUp.DryRun = false
+	if _, err := topUp.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"d4e5f6:30", "g7h8i9:30"}; fmt.Sprint(h.transfers) != fmt.Sprint(want) {
+		t.Errorf("got: %v, want: %v", h.transfers, want)
+	}
+}
+
+func TestTopUpJournalPending(t *testing.T) {
+	h := &topUpHandler{balance: "100", fail: true, t: t}
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	rule := smspartner.TopUpRule{Threshold: smspartner.MustParseMoney("60", ""), Amount: smspartner.MustParseMoney("50", "")}
+	journal := filepath.Join(t.TempDir(), "topup.jsonl")
+	topUp := cli.NewTopUp(journal)
+	topUp.Rules["a1b2c3"] = rule
+	topUp.DailyCap = smspartner.MustParseMoney("60", "")
+
+	// a failed transfer does not count toward the cap
+	entries, err := topUp.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Error == "" || entries[0].Pending {
+		t.Fatalf("unexpected entries: %s", jsonString(entries))
+	}
+	h.fail = false
+	if _, err := topUp.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a1b2c3:50"}; fmt.Sprint(h.transfers) != fmt.Sprint(want) {
+		t.Errorf("got: %v, want: %v", h.transfers, want)
+	}
+
+	// a transfer left pending by a crash counts toward the cap
+	journal = filepath.Join(t.TempDir(), "topup.jsonl")
+	crashed := &smspartner.TopUpEntry{Time: time.Now(), ID: "a1b2c3-1", Pending: true, Token: "a1b2c3", Amount: rule.Amount}
+	if err := os.WriteFile(journal, []byte(jsonString(crashed)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	topUp = cli.NewTopUp(journal)
+	topUp.Rules["a1b2c3"] = rule
+	topUp.DailyCap = smspartner.MustParseMoney("60", "")
+	if entries, err = topUp.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.transfers) != 1 {
+		t.Errorf("unexpected transfers: %v", h.transfers)
+	}
+	if len(entries) != 1 || entries[0].Skipped != smspartner.TopUpSkippedDailyCap {
+		t.Errorf("unexpected entries: %s", jsonString(entries))
+	}
+}
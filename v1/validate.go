@@ -0,0 +1,184 @@
+package smspartner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxSenderLength is the maximum number of characters of a sender.
+const maxSenderLength = 11
+
+// apiLocation is the time zone the API reads scheduled delivery dates in.
+var apiLocation = loadAPILocation()
+
+func loadAPILocation() *time.Location {
+	loc, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// ValidationErrors is returned by the Validate methods. Its items have the
+// same element IDs and messages as the validation errors of the API.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	return (&RemoteAPIError{VError: e}).Error()
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationErrors) add(elementID, format string, args ...interface{}) {
+	*e = append(*e, &ValidationError{ElementID: elementID, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func elementID(field string) string {
+	return fmt.Sprintf("children[%s].data", field)
+}
+
+func listElementID(list string, i int, field string) string {
+	return fmt.Sprintf("children[%s].children[%d].children[%s].data", list, i, field)
+}
+
+// Validate checks the SMS before it is sent. The error is a ValidationErrors.
+func (sms *SMS) Validate() error {
+	var errs ValidationErrors
+	if strings.TrimSpace(sms.Message) == "" {
+		errs.add(elementID("message"), "Le message est requis")
+	}
+	phoneNumbers := sms.PhoneNumbers
+	if sms.Recipients != nil {
+		phoneNumbers = sms.Recipients.String()
+	}
+	validatePhoneNumbers(&errs, elementID("phoneNumbers"), phoneNumbers)
+	if strings.Count(phoneNumbers, ",") >= MaxRecipients {
+		errs.add(elementID("phoneNumbers"), "La liste ne peut pas contenir plus de %d numeros", MaxRecipients)
+	}
+	validateSending(&errs, sms.Gamme, sms.Sender, sms.ScheduledDeliveryDate, sms.Time, sms.Minute)
+	return errs.err()
+}
+
+// Validate checks the bulk SMS before it is sent. The error is a ValidationErrors.
+func (bulksms *BulkSMS) Validate() error {
+	return bulksms.validate(MaxBulkSMS)
+}
+
+// validate checks bulksms, allowing at most limit SMS (no limit if limit < 1).
+func (bulksms *BulkSMS) validate(limit int) error {
+	var errs ValidationErrors
+	switch {
+	case len(bulksms.SMSList) == 0:
+		errs.add(elementID("SMSList"), "La liste des SMS est requise")
+	case limit > 0 && len(bulksms.SMSList) > limit:
+		errs.add(elementID("SMSList"), "La liste ne peut pas contenir plus de %d SMS", limit)
+	}
+	for i, sms := range bulksms.SMSList {
+		if sms == nil {
+			errs.add(listElementID("SMSList", i, "phoneNumber"), "Le numero de telephone est requis")
+			continue
+		}
+		if strings.TrimSpace(sms.Message) == "" {
+			errs.add(listElementID("SMSList", i, "message"), "Le message est requis")
+		}
+		validatePhoneNumbers(&errs, listElementID("SMSList", i, "phoneNumber"), sms.PhoneNumber)
+	}
+	validateSending(&errs, bulksms.Gamme, bulksms.Sender, bulksms.ScheduledDeliveryDate, bulksms.Time, bulksms.Minute)
+	return errs.err()
+}
+
+// Validate checks the virtual number SMS before it is sent. The error is a ValidationErrors.
+func (vn *VNumber) Validate() error {
+	var errs ValidationErrors
+	if strings.TrimSpace(vn.Message) == "" {
+		errs.add(elementID("message"), "Le message est requis")
+	}
+	validatePhoneNumbers(&errs, elementID("to"), vn.To)
+	if vn.From == "" {
+		errs.add(elementID("from"), "Le numero virtuel est requis")
+	} else if !validPhoneNumber(vn.From) {
+		errs.add(elementID("from"), "Ce numero de telephone n'est pas valide (%s)", vn.From)
+	}
+	return errs.err()
+}
+
+func validatePhoneNumbers(errs *ValidationErrors, id, phoneNumbers string) {
+	if strings.TrimSpace(phoneNumbers) == "" {
+		errs.add(id, "Le numero de telephone est requis")
+		return
+	}
+	for _, p := range strings.Split(phoneNumbers, ",") {
+		if p = strings.TrimSpace(p); !validPhoneNumber(p) {
+			errs.add(id, "Ce numero de telephone n'est pas valide (%s)", p)
+		}
+	}
+}
+
+// validPhoneNumber reports whether p looks like a national or international
+// phone number: 8 to 15 digits, optionally separated by spaces, dots or
+// dashes, with an optional leading "+".
+func validPhoneNumber(p string) bool {
+	p = strings.TrimPrefix(p, "+")
+	digits := 0
+	for _, r := range p {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == ' ' || r == '.' || r == '-':
+		default:
+			return false
+		}
+	}
+	return digits >= 8 && digits <= 15
+}
+
+func validateSending(errs *ValidationErrors, gamme Gamme, sender, scheduled string, hour, minute int) {
+	if gamme != 0 && gamme != Premium && gamme != LowCost {
+		errs.add(elementID("gamme"), "La gamme n'est pas valide")
+	}
+	if utf8.RuneCountInString(sender) > maxSenderLength {
+		errs.add(elementID("sender"), "L'emetteur ne peut pas etre plus long que %d caracteres", maxSenderLength)
+	}
+
+	if scheduled == "" {
+		if hour != 0 || minute != 0 {
+			errs.add(elementID("scheduledDeliveryDate"), "La date est requise")
+		}
+		return
+	}
+	day, err := time.ParseInLocation(layout, scheduled, apiLocation)
+	if err != nil {
+		errs.add(elementID("scheduledDeliveryDate"), "La date (%s) n'est pas valide", scheduled)
+		return
+	}
+
+	// Zero is a valid hour and minute: midnight, or on the hour.
+	valid := true
+	if hour < 0 || hour > 23 {
+		errs.add(elementID("time"), "L'heure doit etre comprise entre 0 et 23")
+		valid = false
+	}
+	if minute < 0 || minute > 59 || minute%5 != 0 {
+		errs.add(elementID("minute"), "La minute doit etre un multiple de 5")
+		valid = false
+	}
+	if !valid {
+		return
+	}
+	at := day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	if at.Before(time.Now()) {
+		errs.add(elementID("scheduledDeliveryDate"), "La date (%s à %d:%02d) est anterieure a la date actuelle.",
+			scheduled, hour, minute)
+	}
+}
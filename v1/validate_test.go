@@ -0,0 +1,155 @@
+package smspartner_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hoflish/smspartner-go/v1"
+)
+
+func TestSMSValidateMatchesAPI(t *testing.T) {
+	b, err := fixture("send_sms_error.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := new(smspartner.RemoteAPIError)
+	if err := json.Unmarshal(b, remote); err != nil {
+		t.Fatal(err)
+	}
+
+	sms := &smspartner.SMS{
+		PhoneNumbers:          "922264",
+		Sender:                "MyCompanyName",
+		ScheduledDeliveryDate: "21/11/2014",
+	}
+	err = sms.Validate()
+
+	var got smspartner.ValidationErrors
+	if !errors.As(err, &got) {
+		t.Fatalf("got: %v, want ValidationErrors", err)
+	}
+	// The request of the fixture had no hour and minute, which are now sent
+	// with the date: midnight is in the past, but not missing.
+	want := remote.VError[:4]
+	if len(got) != len(want) || !reflect.DeepEqual([]*smspartner.ValidationError(got[:3]), want[:3]) {
+		t.Fatalf("got: %s\nwant: %s", jsonString(got), jsonString(want))
+	}
+	if got[3].ElementID != want[3].ElementID || got[3].Message != "La date (21/11/2014 à 0:00) est anterieure a la date actuelle." {
+		t.Errorf("got: %s, want: %s", jsonString(got[3]), jsonString(want[3]))
+	}
+}
+
+func TestScheduledSMSJSON(t *testing.T) {
+	for _, tt := range []struct {
+		v    interface{}
+		want string
+	}{
+		{&smspartner.SMS{Message: "Hello", ScheduledDeliveryDate: "21/11/2030"}, `{"message":"Hello","scheduledDeliveryDate":"21/11/2030","time":0,"minute":0}`},
+		{&smspartner.SMS{Message: "Hello"}, `{"message":"Hello"}`},
+		{&smspartner.BulkSMS{ScheduledDeliveryDate: "21/11/2030", Time: 9}, `{"scheduledDeliveryDate":"21/11/2030","time":9,"minute":0}`},
+	} {
+		if got := jsonString(tt.v); got != tt.want {
+			t.Errorf("got: %s, want: %s", got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	next := smspartner.NewDate(time.Now().Year()+1, 3, 10, 9, 30)
+
+	tests := [...]struct {
+		v       interface{ Validate() error }
+		wantIDs []string
+	}{
+		{&smspartner.SMS{PhoneNumbers: "0620123456, +33621123456", Message: "Hello", Gamme: smspartner.LowCost}, nil},
+		{&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Hello", ScheduledDeliveryDate: next.ScheduledDeliveryDate(), Time: 9, Minute: 30}, nil},
+		{&smspartner.SMS{PhoneNumbers: "0620123456,abc", Message: "Hello", Gamme: 3}, []string{"children[phoneNumbers].data", "children[gamme].data"}},
+		{&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Hello", ScheduledDeliveryDate: next.ScheduledDeliveryDate(), Time: 9, Minute: 32}, []string{"children[minute].data"}},
+		{&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Hello", ScheduledDeliveryDate: "2019-01-01", Time: 9, Minute: 30}, []string{"children[scheduledDeliveryDate].data"}},
+		{&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Hello", ScheduledDeliveryDate: next.ScheduledDeliveryDate(), Time: 9}, nil},
+		{&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Hello", ScheduledDeliveryDate: next.ScheduledDeliveryDate()}, nil},
+		{&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Hello", ScheduledDeliveryDate: next.ScheduledDeliveryDate(), Time: 24}, []string{"children[time].data"}},
+		{&smspartner.SMS{PhoneNumbers: "0620123456", Message: "Hello", Time: 9, Minute: 30}, []string{"children[scheduledDeliveryDate].data"}},
+		{
+			&smspartner.BulkSMS{SMSList: []*smspartner.SMSPayload{{PhoneNumber: "0620123456", Message: "Hello"}, {PhoneNumber: "06", Message: ""}}},
+			[]string{"children[SMSList].children[1].children[message].data", "children[SMSList].children[1].children[phoneNumber].data"},
+		},
+		{&smspartner.BulkSMS{}, []string{"children[SMSList].data"}},
+		{&smspartner.BulkSMS{SMSList: testSMSList(501)}, []string{"children[SMSList].data"}},
+		{&smspartner.VNumber{To: "+33620123456", From: "+33757000000", Message: "Hello"}, nil},
+		{&smspartner.VNumber{To: "+33620123456"}, []string{"children[message].data", "children[from].data"}},
+	}
+
+	for i, tt := range tests {
+		err := tt.v.Validate()
+
+		var gotIDs []string
+		var verrs smspartner.ValidationErrors
+		if errors.As(err, &verrs) {
+			for _, e := range verrs {
+				gotIDs = append(gotIDs, e.ElementID)
+			}
+		} else if err != nil {
+			t.Fatalf("#%d. unexpected error type %T", i, err)
+		}
+		if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+			t.Errorf("#%d. got: %v, want: %v", i, gotIDs, tt.wantIDs)
+		}
+	}
+}
+
+func TestSendSMSValidatesBeforeSending(t *testing.T) {
+	var called bool
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+
+	if _, err := cli.SendSMS(&smspartner.SMS{PhoneNumbers: "0620123456"}); err == nil {
+		t.Error("expected a validation error")
+	}
+	if _, err := cli.SendBulkSMS(&smspartner.BulkSMS{}); err == nil {
+		t.Error("expected a validation error")
+	}
+	if called {
+		t.Error("API should not be called")
+	}
+}
+
+func TestValidateBeforeSuppress(t *testing.T) {
+	var requests int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	})
+
+	cli, teardown := testingHTTPClient(t, h)
+	defer teardown()
+	cli, teardown = testingHTTPClient(t, h, smspartner.Suppress(cli.NewStopList("")), smspartner.FilterNumbers(&smspartner.NumberFilter{}))
+	defer teardown()
+
+	if _, err := cli.SendSMS(&smspartner.SMS{PhoneNumbers: "0620123456"}); err == nil {
+		t.Error("expected a validation error")
+	}
+	list := []*smspartner.SMSPayload{{PhoneNumber: "+4915123456789"}}
+	if _, err := cli.SendBulkSMS(&smspartner.BulkSMS{SMSList: list}); err == nil {
+		t.Error("expected a validation error")
+	}
+	if _, err := cli.SendBulkSMSBatches(context.Background(), &smspartner.BulkSMS{SMSList: list}, 1); err == nil {
+		t.Error("expected a validation error")
+	}
+	if requests != 0 {
+		t.Errorf("got %d requests, want: 0", requests)
+	}
+}
+
+func jsonString(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -2,31 +2,47 @@ package smspartner
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 )
 
 type NumberVerificationRequest struct {
 	APIKey       string `json:"apiKey,omitempty"`
 	PhoneNumbers string `json:"phoneNumbers,omitempty"`
 	NotifyURL    string `json:"notifyUrl,omitempty"`
+
+	// Recipients, when set, replaces PhoneNumbers.
+	Recipients Recipients `json:"-"`
 }
 
 type NumberVerificationResponse struct {
-	Success    bool    `json:"success,omitempty"`
-	Code       int     `json:"code,omitempty"`
-	CampaignID string  `json:"campaign_id,omitempty"`
-	Number     int     `json:"number,omitempty"`
-	Cost       float64 `json:"cost,omitempty"`
-	Currency   string  `json:"currency,omitempty"`
+	Success    bool   `json:"success,omitempty"`
+	Code       int    `json:"code,omitempty"`
+	CampaignID string `json:"campaign_id,omitempty"`
+	Number     int    `json:"number,omitempty"`
+	Cost       Money  `json:"cost"`
+	Currency   string `json:"currency,omitempty"`
+}
+
+func (r *NumberVerificationResponse) UnmarshalJSON(b []byte) error {
+	type response NumberVerificationResponse
+	if err := json.Unmarshal(b, (*response)(r)); err != nil {
+		return err
+	}
+	r.Cost.Currency = r.Currency
+	return nil
 }
 
-// VerifyNumber checks that a phone number actually exists.
+// VerifyNumber checks that a phone number actually exists. The results are
+// sent later to NotifyURL, see HLRCorrelator.
 func (c *Client) VerifyNumber(reqPayload *NumberVerificationRequest) (*NumberVerificationResponse, error) {
 	reqPayload.APIKey = c.apiKey
+	if reqPayload.Recipients != nil {
+		reqPayload.PhoneNumbers = reqPayload.Recipients.String()
+	}
 
 	blob, err := json.Marshal(reqPayload)
 	if err != nil {
@@ -48,6 +64,17 @@ func (c *Client) VerifyNumber(reqPayload *NumberVerificationRequest) (*NumberVer
 	if err := json.Unmarshal(blob, &nvr); err != nil {
 		return nil, err
 	}
+	recipients := splitPhoneNumbers(reqPayload.PhoneNumbers)
+	c.bill(Money{}, &LedgerEntry{
+		Endpoint:   "hlr/notify",
+		CampaignID: nvr.CampaignID,
+		Recipients: recipients,
+		Cost:       nvr.Cost,
+		Countries:  splitCost(recipients, 0, nvr.Cost),
+	})
+	if c.hlr != nil && nvr.CampaignID != "" {
+		c.hlr.Expect(nvr.CampaignID, nvr.Number)
+	}
 	return nvr, nil
 }
 
@@ -67,6 +94,7 @@ type Lookup struct {
 	Type        string        `json:"type,omitempty"`
 	Network     string        `json:"network,omitempty"`
 	Format      *NumberFormat `json:"format,omitempty"`
+	Message     string        `json:"message,omitempty"`
 }
 
 type LookupResponse struct {
@@ -75,36 +103,25 @@ type LookupResponse struct {
 	Lookup  []*Lookup `json:"lookup,omitempty"`
 }
 
-// VerifyNumberFormat checks the format of a phone number
+// VerifyNumberFormat checks the format of a phone number. The numbers are
+// sent in requests of at most MaxLookupNumbers, see also LookupNumbers.
 func (c *Client) VerifyNumberFormat(phoneNumbers ...string) (*LookupResponse, error) {
 	if len(phoneNumbers) == 0 {
 		return nil, errors.New("At least one phoneNumber is required")
 	}
-	p := strings.Join(phoneNumbers, ",")
-
-	payload := new(NumberVerificationRequest)
-	payload.APIKey = c.apiKey
-	payload.PhoneNumbers = p
-
-	blob, err := json.Marshal(payload)
-	if err != nil {
-		return nil, err
-	}
-	fullURL := fmt.Sprintf("%s/lookup", c.basePath)
-
-	req, err := http.NewRequest("POST", fullURL, bytes.NewReader(blob))
-	if err != nil {
-		return nil, err
-	}
-
-	blob, err = c.doRequest(req)
-	if err != nil {
-		return nil, err
-	}
 
 	lr := new(LookupResponse)
-	if err := json.Unmarshal(blob, lr); err != nil {
-		return nil, err
+	for start := 0; start < len(phoneNumbers); start += MaxLookupNumbers {
+		end := start + MaxLookupNumbers
+		if end > len(phoneNumbers) {
+			end = len(phoneNumbers)
+		}
+		resp, err := c.lookup(context.Background(), phoneNumbers[start:end])
+		if err != nil {
+			return nil, err
+		}
+		lr.Success, lr.Code = resp.Success, resp.Code
+		lr.Lookup = append(lr.Lookup, resp.Lookup...)
 	}
 	return lr, nil
 }